@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/bot"
+)
+
+// runBot runs a strategy on the connection until interrupted
+func runBot(cx *cliContext, args []string) error {
+	if len(args) != 2 || args[0] != "run" {
+		return usagef("usage: bot run <strategy>")
+	}
+	strategy, err := bot.Lookup(args[1])
+	if err != nil {
+		return usagef("%v", err)
+	}
+
+	b := bot.New(cx.client, strategy)
+	b.Rules = cx.rules
+	b.Logger = slog.Default().With("user", cx.player.Username)
+
+	// Seed the world from the snapshot connect already consumed
+	b.Apply(api.StateEvent{Player: *cx.player})
+	b.Apply(api.RoutesEvent{Routes: cx.routes})
+	b.Apply(api.PoisEvent{Pois: cx.pois})
+	b.Logger.Info("bot running", "strategy", args[1])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := b.Run(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	b.Logger.Info("bot stopped")
+	return nil
+}
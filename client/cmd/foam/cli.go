@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/bot"
+	"github.com/philip/foam/internal/config"
+	"github.com/philip/foam/internal/geo"
+	"github.com/philip/foam/internal/rules"
+)
+
+// Exit codes for the one-shot commands
+const (
+	exitOK      = 0
+	exitFailed  = 1 // The server rejected the request
+	exitUsage   = 2 // Bad arguments
+	exitConnect = 3 // Couldn't connect or authenticate
+	exitTimeout = 4 // No answer in time
+)
+
+// command is a headless subcommand: connect, do one thing, print, exit
+type command struct {
+	usage     string // Arguments after the command name
+	help      string
+	run       func(cx *cliContext, args []string) error
+	longLived bool // Keeps running and reconnecting; --timeout only bounds the connect
+}
+
+var commands = map[string]command{
+	"status": {"", "show nits, production, heat and counts", runStatus, false},
+	"routes": {"", "list our routes", runRoutes, false},
+	"pois":   {"", "list the POIs we know about", runPois, false},
+	"invest": {"<poi> <nits>", "invest in a POI (id or unique id prefix)", runInvest, false},
+	"order":  {"<bid|ask> <price> <amount>", "place a market order", runOrder, false},
+	"route":  {"<request|accept|reject|withdraw> <user|route>", "manage route requests", runRoute, false},
+	"claim":  {"", "claim the username and store its credentials", runClaim, false},
+	"bot":    {"run <strategy>", fmt.Sprintf("run a Go bot strategy %v until interrupted", bot.Names()), runBot, true},
+}
+
+// commandNames lists the subcommands in a stable order for usage output
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usageError is reported with exitUsage
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// connectError is reported with exitConnect
+type connectError struct{ err error }
+
+func (e *connectError) Error() string { return e.err.Error() }
+func (e *connectError) Unwrap() error { return e.err }
+
+// cliContext is one headless run's connection and what the server told us
+type cliContext struct {
+	ctx    context.Context
+	client *api.Client
+	json   bool
+	out    io.Writer
+	rules  rules.Params
+
+	player *api.PlayerState
+	routes []api.RouteState
+	pois   []api.IntersectionState
+}
+
+// runCommand runs a subcommand and returns the process exit code
+func runCommand(name string, args []string, serverURL, username string, session *api.Session, params rules.Params) int {
+	cmd := commands[name]
+
+	fs := flag.NewFlagSet("foam "+name, flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	timeout := fs.Duration("timeout", 15*time.Second, "give up after `duration`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: foam [flags] %s [--json] [--timeout d] %s\n\n%s\n", name, cmd.usage, cmd.help)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cx := &cliContext{ctx: ctx, json: *asJSON, out: os.Stdout, rules: params}
+	err := cx.connect(serverURL, username, session, cmd.longLived)
+	if err == nil {
+		err = cmd.run(cx, fs.Args())
+	}
+	if cx.client != nil {
+		cx.client.Close()
+	}
+
+	if err == nil {
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "foam %s: %v\n", name, err)
+
+	var usage *usageError
+	var conn *connectError
+	switch {
+	case errors.As(err, &usage):
+		fs.Usage()
+		return exitUsage
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.As(err, &conn):
+		return exitConnect
+	default:
+		return exitFailed
+	}
+}
+
+// connect dials and waits for the server's snapshot: connected, state,
+// routes and finally pois. One-shot commands don't reconnect.
+func (cx *cliContext) connect(serverURL, username string, session *api.Session, reconnect bool) error {
+	client := api.NewClient(serverURL, username)
+	client.Logger = slog.Default()
+	if !reconnect {
+		client.Backoff.MaxAttempts = -1
+	}
+	if session != nil {
+		client.SetToken(session.Token)
+	}
+	if err := client.Connect(); err != nil {
+		return &connectError{err}
+	}
+	cx.client = client
+
+	for {
+		select {
+		case ev := <-client.Messages:
+			switch ev := ev.(type) {
+			case api.ErrorEvent:
+				if ev.Code != "" {
+					return &connectError{&api.ServerError{Message: ev.Message, Code: ev.Code}}
+				}
+				return &api.ServerError{Message: ev.Message}
+			case api.StateEvent:
+				player := ev.Player
+				cx.player = &player
+			case api.RoutesEvent:
+				cx.routes = ev.Routes
+			case api.PoisEvent:
+				cx.pois = ev.Pois
+				return nil
+			}
+		case err := <-client.Errors:
+			return &connectError{err}
+		case <-client.Done:
+			return &connectError{api.ErrClosed}
+		case <-cx.ctx.Done():
+			if cx.player == nil {
+				return fmt.Errorf("waiting for the server: %w", cx.ctx.Err())
+			}
+			return fmt.Errorf("waiting for routes and POIs (server too old?): %w", cx.ctx.Err())
+		}
+	}
+}
+
+// print writes v as JSON, or calls text for the human-readable form
+func (cx *cliContext) print(v any, text func(w io.Writer)) error {
+	if cx.json {
+		enc := json.NewEncoder(cx.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(cx.out)
+	return nil
+}
+
+func (cx *cliContext) controlled() int {
+	n := 0
+	for _, poi := range cx.pois {
+		if poi.Controller == cx.player.Username {
+			n++
+		}
+	}
+	return n
+}
+
+func runStatus(cx *cliContext, args []string) error {
+	if len(args) != 0 {
+		return usagef("status takes no arguments")
+	}
+	p := cx.player
+	r := cx.rules
+	tier := r.Tier(p.Heat)
+
+	status := struct {
+		Player     *api.PlayerState `json:"player"`
+		Production float64          `json:"production"`
+		Tier       string           `json:"tier"`
+		Routes     int              `json:"routes"`
+		Pois       int              `json:"pois"`
+		Controlled int              `json:"controlled"`
+	}{p, r.Production(p.ProductionRate, cx.controlled()), tier.Name, len(cx.routes), len(cx.pois), cx.controlled()}
+
+	return cx.print(status, func(w io.Writer) {
+		fmt.Fprintf(w, "%s  %s, %s\n", p.Username, p.City, p.Region)
+		fmt.Fprintf(w, "nits    %d (+%.1f/tick)\n", p.Nits, status.Production)
+		fmt.Fprintf(w, "heat    %d (%s)\n", p.Heat, tier.Name)
+		fmt.Fprintf(w, "routes  %d\n", status.Routes)
+		fmt.Fprintf(w, "pois    %d (%d controlled)\n", status.Pois, status.Controlled)
+	})
+}
+
+func runRoutes(cx *cliContext, args []string) error {
+	if len(args) != 0 {
+		return usagef("routes takes no arguments")
+	}
+	routes := cx.routes
+	if routes == nil {
+		routes = []api.RouteState{}
+	}
+	return cx.print(routes, func(w io.Writer) {
+		if len(routes) == 0 {
+			fmt.Fprintln(w, "No routes")
+			return
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tPARTNER\tSTATUS\tCAPACITY\tLENGTH")
+		for _, r := range routes {
+			partner := r.PlayerB
+			if partner == cx.player.Username {
+				partner = r.PlayerA
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f km\n", r.Id, partner, r.Status, r.Capacity, geo.Distance(r.CoordsA, r.CoordsB))
+		}
+		tw.Flush()
+	})
+}
+
+func runPois(cx *cliContext, args []string) error {
+	if len(args) != 0 {
+		return usagef("pois takes no arguments")
+	}
+	pois := cx.pois
+	if pois == nil {
+		pois = []api.IntersectionState{}
+	}
+	return cx.print(pois, func(w io.Writer) {
+		if len(pois) == 0 {
+			fmt.Fprintln(w, "No POIs")
+			return
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tLOCATION\tCONTROLLER\tOURS\tTOTAL")
+		for _, poi := range pois {
+			controller := poi.Controller
+			if controller == "" {
+				controller = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%.4f,%.4f\t%s\t%d\t%d\n", poi.Id, poi.Coordinates.Lat, poi.Coordinates.Lng,
+				controller, poi.Investments[cx.player.Username], poi.TotalInvested)
+		}
+		tw.Flush()
+	})
+}
+
+// findPoi resolves a POI by id or unique id prefix
+func (cx *cliContext) findPoi(ref string) (api.IntersectionState, error) {
+	var matches []api.IntersectionState
+	for _, poi := range cx.pois {
+		if poi.Id == ref {
+			return poi, nil
+		}
+		if strings.HasPrefix(poi.Id, ref) {
+			matches = append(matches, poi)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return api.IntersectionState{}, fmt.Errorf("no known POI matches %q (see foam pois)", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return api.IntersectionState{}, fmt.Errorf("%q matches %d POIs; use more of the id", ref, len(matches))
+	}
+}
+
+func runInvest(cx *cliContext, args []string) error {
+	if len(args) != 2 {
+		return usagef("invest needs a POI and an amount")
+	}
+	poi, err := cx.findPoi(args[0])
+	if err != nil {
+		return err
+	}
+	amount, err := strconv.Atoi(args[1])
+	if err != nil || amount <= 0 {
+		return usagef("invalid amount %q", args[1])
+	}
+
+	ack, err := cx.client.InvestPoiContext(cx.ctx, poi.Id, amount)
+	if err != nil {
+		return err
+	}
+	return cx.print(ack, func(w io.Writer) {
+		fmt.Fprintf(w, "Invested %d nits in POI %s\n", amount, poi.Id)
+	})
+}
+
+func runOrder(cx *cliContext, args []string) error {
+	if len(args) != 3 {
+		return usagef("order needs a side, price and amount")
+	}
+	side := args[0]
+	if side != "bid" && side != "ask" {
+		return usagef("side must be bid or ask, not %q", side)
+	}
+	price, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || price <= 0 {
+		return usagef("invalid price %q", args[1])
+	}
+	amount, err := strconv.Atoi(args[2])
+	if err != nil || amount <= 0 {
+		return usagef("invalid amount %q", args[2])
+	}
+
+	ack, err := cx.client.PlaceOrderContext(cx.ctx, side, price, amount)
+	if err != nil {
+		return err
+	}
+	return cx.print(ack, func(w io.Writer) {
+		fmt.Fprintf(w, "Placed %s %d @ %.2f (order %s)\n", side, amount, price, ack.OrderId)
+	})
+}
+
+func runRoute(cx *cliContext, args []string) error {
+	if len(args) != 2 {
+		return usagef("route needs an action and a user or route id")
+	}
+	action, target := args[0], args[1]
+
+	var ack api.AckEvent
+	var err error
+	var done string
+	switch action {
+	case "request":
+		ack, err = cx.client.RequestRouteContext(cx.ctx, target)
+		done = fmt.Sprintf("Requested a route to %s (route %s)", target, ack.RouteId)
+	case "accept":
+		ack, err = cx.client.AcceptRouteContext(cx.ctx, target)
+		done = "Accepted route " + target
+	case "reject":
+		ack, err = cx.client.RejectRouteContext(cx.ctx, target)
+		done = "Rejected route " + target
+	case "withdraw":
+		ack, err = cx.client.WithdrawRouteContext(cx.ctx, target)
+		done = "Withdrew route request " + target
+	default:
+		return usagef("unknown route action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+	return cx.print(ack, func(w io.Writer) {
+		fmt.Fprintln(w, done)
+	})
+}
+
+// runClaim claims the username over the connection, which works for a
+// player that already exists, and stores the session for later runs
+func runClaim(cx *cliContext, args []string) error {
+	if len(args) != 0 {
+		return usagef("claim takes no arguments")
+	}
+	session, err := cx.client.ClaimContext(cx.ctx)
+	if err != nil {
+		return err
+	}
+	if err := config.SaveSession(cx.client.URL, session); err != nil {
+		return err
+	}
+	return cx.print(struct {
+		Username  string `json:"username"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}{session.Username, session.ExpiresAt}, func(w io.Writer) {
+		fmt.Fprintf(w, "Claimed %s; credentials stored\n", session.Username)
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/philip/foam/internal/config"
+	"github.com/philip/foam/internal/logging"
+)
+
+// logPaneSize is how many records the TUI keeps for its log pane
+const logPaneSize = 200
+
+// setupLogging installs the default slog logger, which the api client, the
+// TUI and the standard log package all write through. Records go to
+// cfg.LogFile when set; with --debug and no file the TUI logs to foam.log
+// in the state dir. Headless commands without a file log warnings (or,
+// with --debug, everything) to stderr; long-lived ones log from info, so
+// a bot's actions show. The TUI keeps recent records for its log pane.
+// The returned func closes the file.
+func setupLogging(cfg *config.Config, debug, headless, longLived bool) (*logging.Ring, func(), error) {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	if cfg.LogFile == "" && debug && !headless {
+		dir, err := config.StateDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.LogFile = filepath.Join(dir, "foam.log")
+	}
+
+	var handlers []slog.Handler
+	closeLog := func() {}
+	switch {
+	case cfg.LogFile != "":
+		h, f, err := logging.Open(cfg.LogFile, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		handlers = append(handlers, h)
+		closeLog = func() { f.Close() }
+	case headless:
+		stderrLevel := slog.LevelWarn
+		switch {
+		case debug:
+			stderrLevel = slog.LevelDebug
+		case longLived:
+			stderrLevel = slog.LevelInfo
+		}
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: stderrLevel}))
+	}
+
+	var ring *logging.Ring
+	if !headless {
+		ring = logging.NewRing(logPaneSize, level)
+		handlers = append(handlers, ring)
+	}
+	slog.SetDefault(slog.New(logging.Tee(handlers...)))
+	return ring, closeLog, nil
+}
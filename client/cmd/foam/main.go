@@ -1,33 +1,163 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"time"
 
-	"github.com/philip/foam/internal/tui"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/alert"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/config"
+	"github.com/philip/foam/internal/rules"
+	"github.com/philip/foam/internal/tui"
 )
 
 func main() {
-	// Default server URL (local dev)
-	serverURL := "ws://localhost:8787/ws"
+	defaultPath, _ := config.Path()
 
-	// Get username from args or prompt
-	var username string
-	if len(os.Args) > 1 {
-		username = os.Args[1]
-	} else {
+	configPath := flag.String("config", defaultPath, "config file `path`")
+	server := flag.String("server", "", "server profile name or ws:// URL (env FOAM_SERVER)")
+	user := flag.String("user", "", "username, 1-7 alphanumeric (env FOAM_USER)")
+	theme := flag.String("theme", "", fmt.Sprintf("color theme %v (env FOAM_THEME)", tui.ThemeNames()))
+	logFile := flag.String("log-file", "", "write logs to `path` (env FOAM_LOG_FILE)")
+	debug := flag.Bool("debug", false, "log frames and other debug detail (to foam.log in the state dir unless --log-file is set)")
+	noClaim := flag.Bool("no-claim", false, "don't claim the username when the TUI first connects")
+	record := flag.String("record", "", "record the session's frames to `path` for foam replay")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: foam [flags] [username]\n")
+		fmt.Fprintf(out, "       foam [flags] <command> [--json] [--timeout d] [args]\n")
+		fmt.Fprintf(out, "       foam replay [--speed n] <file>\n\nCommands:\n")
+		for _, name := range commandNames() {
+			cmd := commands[name]
+			fmt.Fprintf(out, "  %-7s %-48s %s\n", name, cmd.usage, cmd.help)
+		}
+		fmt.Fprintf(out, "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Precedence: flags > environment > config file > defaults
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cfg.ApplyEnv()
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *user != "" {
+		cfg.User = *user
+	}
+	// A known command runs headless; anything else names the user
+	var sub string
+	if flag.NArg() > 0 {
+		if _, ok := commands[flag.Arg(0)]; ok {
+			sub = flag.Arg(0)
+		} else {
+			cfg.User = flag.Arg(0)
+		}
+	}
+	if *theme != "" {
+		cfg.Theme = *theme
+	}
+	if *logFile != "" {
+		cfg.LogFile = *logFile
+	}
+
+	logRing, closeLog, err := setupLogging(&cfg, *debug, sub != "", commands[sub].longLived)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	if flag.Arg(0) == "replay" {
+		os.Exit(runReplay(flag.Args()[1:], &cfg, logRing))
+	}
+
+	serverURL, err := cfg.ResolveServer()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Get username from config or prompt
+	username := cfg.User
+	if username == "" && sub == "" {
 		fmt.Print("Enter username (1-7 alphanumeric): ")
 		fmt.Scanln(&username)
 	}
 
 	if username == "" {
-		fmt.Println("Username required")
+		fmt.Println("Username required (--user or FOAM_USER)")
+		os.Exit(exitUsage)
+	}
+	if err := config.ValidateUsername(username); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	if err := tui.ApplyTheme(cfg.Theme); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	notifier, err := alert.New(cfg.Alerts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	params, err := rules.Lookup(cfg.Rules)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	slog.Info("starting", "server", serverURL, "user", username, "command", sub)
+
+	// Headless commands only use stored credentials; `foam claim` claims
+	session, err := loadSession(serverURL, username, !*noClaim && sub == "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if sub != "" {
+		os.Exit(runCommand(sub, flag.Args()[1:], serverURL, username, session, params))
+	}
+
 	// Create and run the TUI
 	app := tui.NewApp(serverURL, username)
+	if session != nil {
+		app.SetSession(*session, func(s api.Session) error {
+			return config.SaveSession(serverURL, s)
+		})
+	}
+	app.SetAlerts(notifier, os.Stdout)
+	app.SetRules(params)
+	app.SetLogger(slog.Default(), logRing)
+	if *record != "" {
+		rec, err := api.CreateRecording(*record)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer rec.Close()
+		app.SetRecorder(rec)
+	}
+	if dir, err := config.StateDir(); err == nil {
+		if err := app.SetFillsLog(filepath.Join(dir, "fills.jsonl")); err != nil {
+			slog.Warn("fills history unavailable", "err", err)
+		}
+		if err := app.SetEventLog(filepath.Join(dir, "events.jsonl")); err != nil {
+			slog.Warn("event log unavailable", "err", err)
+		}
+	}
 	p := tea.NewProgram(app, tea.WithAltScreen())
 
 	if _, err := p.Run(); err != nil {
@@ -35,3 +165,52 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// loadSession returns the stored session for username, refreshing it if it
+// has expired, or claims the username when nothing is stored. A nil session
+// means connecting without a token (unclaimed or an older server).
+func loadSession(serverURL, username string, claim bool) (*api.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, ok, err := config.LoadSession(serverURL, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		if !claim {
+			return nil, nil
+		}
+		s, err = api.Claim(ctx, serverURL, username)
+		switch {
+		case errors.Is(err, api.ErrAlreadyClaimed):
+			return nil, fmt.Errorf("%s is already claimed on this server and no credentials are stored", username)
+		case errors.Is(err, api.ErrAuthUnsupported):
+			return nil, nil
+		case errors.Is(err, api.ErrPlayerExists):
+			slog.Warn("username is in use unclaimed; run foam claim to claim it", "user", username)
+			return nil, nil
+		case err != nil:
+			// Let the TUI report connection problems as usual
+			slog.Warn("claim failed", "user", username, "err", err)
+			return nil, nil
+		}
+	} else if s.Expired() {
+		refreshed, err := api.Refresh(ctx, serverURL, s)
+		if err != nil {
+			// Keep the stale session; the TUI prompts for a refresh when
+			// the server turns it away
+			slog.Warn("session refresh failed", "user", username, "err", err)
+			return &s, nil
+		}
+		s = refreshed
+	} else {
+		return &s, nil
+	}
+
+	if err := config.SaveSession(serverURL, s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/config"
+	"github.com/philip/foam/internal/logging"
+	"github.com/philip/foam/internal/rules"
+	"github.com/philip/foam/internal/tui"
+)
+
+// runReplay plays a --record recording in the TUI without a server and
+// returns the process exit code
+func runReplay(args []string, cfg *config.Config, logRing *logging.Ring) int {
+	fs := flag.NewFlagSet("foam replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 1, "playback `rate` (1 is real time; space pauses, . steps, < and > change it)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: foam replay [--speed n] <file>\n\nPlay back a session recorded with --record.\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || *speed <= 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	frames, err := api.LoadRecording(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "foam replay: %v\n", err)
+		return exitFailed
+	}
+	if err := tui.ApplyTheme(cfg.Theme); err != nil {
+		fmt.Fprintf(os.Stderr, "foam replay: %v\n", err)
+		return exitFailed
+	}
+	params, err := rules.Lookup(cfg.Rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "foam replay: %v\n", err)
+		return exitFailed
+	}
+
+	app := tui.NewReplay(frames, *speed)
+	app.SetRules(params)
+	app.SetLogger(slog.Default(), logRing)
+	if _, err := tea.NewProgram(app, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "foam replay: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,218 @@
+// Package alert rings the terminal bell, sends desktop notifications and
+// runs shell hooks for game events matching the configured rules.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/philip/foam/internal/config"
+)
+
+// Triggers a rule can fire on
+const (
+	POIAttacked  = "poi_attacked"  // Someone invested in a POI we control
+	ControlLost  = "control_lost"  // A POI we controlled changed hands
+	Toll         = "toll"          // Toll income arrived
+	RouteRequest = "route_request" // Someone asked us for a route
+)
+
+var triggers = []string{POIAttacked, ControlLost, Toll, RouteRequest}
+
+// Desktop notification escapes understood by common terminals
+const (
+	NotifyOSC9   = "osc9"   // iTerm2, WezTerm, Windows Terminal, kitty
+	NotifyOSC777 = "osc777" // urxvt, foot, VTE-based terminals
+)
+
+// Alert is one event that may need attention
+type Alert struct {
+	Trigger string
+	Title   string
+	Message string
+	Amount  int // Nits involved, compared against a rule's threshold
+}
+
+// DefaultRules ring the bell for attacks when no [alerts] section exists
+func DefaultRules() []config.AlertRule {
+	return []config.AlertRule{
+		{On: POIAttacked, Bell: true},
+		{On: ControlLost, Bell: true},
+	}
+}
+
+// Notifier delivers alerts according to a set of rules
+type Notifier struct {
+	rules []config.AlertRule
+	quiet *quietHours
+	now   func() time.Time
+}
+
+// New validates cfg and returns a Notifier. A nil cfg uses DefaultRules.
+func New(cfg *config.Alerts) (*Notifier, error) {
+	n := &Notifier{now: time.Now}
+	if cfg == nil {
+		n.rules = DefaultRules()
+		return n, nil
+	}
+
+	for i, r := range cfg.Rules {
+		if !containsString(triggers, r.On) {
+			return nil, fmt.Errorf("alert rule %d: unknown trigger %q (expected one of %s)",
+				i+1, r.On, strings.Join(triggers, ", "))
+		}
+		switch r.Notify {
+		case "", NotifyOSC9, NotifyOSC777:
+		default:
+			return nil, fmt.Errorf("alert rule %d: unknown notify %q (expected %s or %s)",
+				i+1, r.Notify, NotifyOSC9, NotifyOSC777)
+		}
+	}
+	n.rules = cfg.Rules
+
+	if cfg.QuietHours != "" {
+		q, err := parseQuietHours(cfg.QuietHours)
+		if err != nil {
+			return nil, err
+		}
+		n.quiet = &q
+	}
+	return n, nil
+}
+
+// Match returns the rules that fire for a
+func (n *Notifier) Match(a Alert) []config.AlertRule {
+	var out []config.AlertRule
+	for _, r := range n.rules {
+		if r.On == a.Trigger && a.Amount >= r.Threshold {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Quiet reports whether t falls within the quiet hours
+func (n *Notifier) Quiet(t time.Time) bool {
+	return n.quiet != nil && n.quiet.contains(t)
+}
+
+// Escapes returns the bell and desktop notification escapes for a's
+// matching rules, or "" during quiet hours. The caller writes them to the
+// terminal in a single write, so they don't tear a frame being drawn.
+func (n *Notifier) Escapes(a Alert) string {
+	if n.Quiet(n.now()) {
+		return ""
+	}
+
+	var esc strings.Builder
+	for _, r := range n.Match(a) {
+		if r.Bell {
+			esc.WriteString("\a")
+		}
+		switch r.Notify {
+		case NotifyOSC9:
+			fmt.Fprintf(&esc, "\x1b]9;%s: %s\a", sanitize(a.Title), sanitize(a.Message))
+		case NotifyOSC777:
+			fmt.Fprintf(&esc, "\x1b]777;notify;%s;%s\a",
+				strings.ReplaceAll(sanitize(a.Title), ";", ","), sanitize(a.Message))
+		}
+	}
+	return esc.String()
+}
+
+// Fire runs the shell hooks of a's matching rules, unless it's quiet
+// hours. Hooks run to completion, so call it off the UI goroutine.
+func (n *Notifier) Fire(ctx context.Context, a Alert) error {
+	if n.Quiet(n.now()) {
+		return nil
+	}
+
+	var errs []error
+	for _, r := range n.Match(a) {
+		if r.Command != "" {
+			if err := runHook(ctx, r.Command, a); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runHook runs command with sh, describing the alert in its environment.
+// Output is discarded; it would corrupt the TUI.
+func runHook(ctx context.Context, command string, a Alert) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"FOAM_ALERT_EVENT="+a.Trigger,
+		"FOAM_ALERT_TITLE="+a.Title,
+		"FOAM_ALERT_MESSAGE="+a.Message,
+		"FOAM_ALERT_AMOUNT="+strconv.Itoa(a.Amount),
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("alert hook %q: %w", command, err)
+	}
+	return nil
+}
+
+// sanitize strips control characters that would end an escape early
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// quietHours is a daily local-time window, possibly spanning midnight
+type quietHours struct {
+	start, end int // Minutes after midnight
+}
+
+// parseQuietHours reads "HH:MM-HH:MM"
+func parseQuietHours(s string) (quietHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("invalid quiet_hours %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return quietHours{}, fmt.Errorf("invalid quiet_hours %q: %w", s, err)
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return quietHours{}, fmt.Errorf("invalid quiet_hours %q: %w", s, err)
+	}
+	return quietHours{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q quietHours) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if q.start <= q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Auth error codes carried by ErrorEvent.Code
+const (
+	CodeAuthRequired = "auth_required" // The username is claimed and no valid token was given
+	CodeAuthExpired  = "auth_expired"  // The session token has expired; refresh it
+)
+
+var (
+	// ErrAlreadyClaimed means someone else holds the username's credentials
+	ErrAlreadyClaimed = errors.New("username already claimed")
+	// ErrPlayerExists means the username is already playing unclaimed; it
+	// can only be claimed over its own connection (see ClaimContext)
+	ErrPlayerExists = errors.New("player exists; claim it from a connected session")
+	// ErrInvalidRefresh means the refresh token was rejected; claim again
+	ErrInvalidRefresh = errors.New("refresh token rejected")
+	// ErrAuthUnsupported means the server predates username claims
+	ErrAuthUnsupported = errors.New("server does not support username claims")
+)
+
+// Session is a claimed username's credentials
+type Session struct {
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    int64  `json:"expiresAt"` // Unix milliseconds, as sent by the server
+}
+
+// Expired reports whether the session token has expired (or will within a
+// minute, to leave room for the handshake)
+func (s Session) Expired() bool {
+	return time.Now().Add(time.Minute).UnixMilli() >= s.ExpiresAt
+}
+
+// HTTPBaseURL derives the server's HTTP origin from its WebSocket URL
+func HTTPBaseURL(wsURL string) (string, error) {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.Path, u.RawQuery = "", ""
+	return u.String(), nil
+}
+
+// Claim claims a username nobody has played yet and returns its first
+// session
+func Claim(ctx context.Context, wsURL, username string) (Session, error) {
+	return postAuth(ctx, wsURL, "/auth/claim", map[string]string{"username": username})
+}
+
+// Refresh trades a refresh token for a new session token
+func Refresh(ctx context.Context, wsURL string, s Session) (Session, error) {
+	return postAuth(ctx, wsURL, "/auth/refresh", map[string]string{
+		"username":     s.Username,
+		"refreshToken": s.RefreshToken,
+	})
+}
+
+func postAuth(ctx context.Context, wsURL, path string, body map[string]string) (Session, error) {
+	base, err := HTTPBaseURL(wsURL)
+	if err != nil {
+		return Session{}, err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Session{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+path, bytes.NewReader(data))
+	if err != nil {
+		return Session{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Session{}, fmt.Errorf("auth request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		return Session{}, ErrAlreadyClaimed
+	case http.StatusForbidden:
+		return Session{}, ErrPlayerExists
+	case http.StatusUnauthorized:
+		return Session{}, ErrInvalidRefresh
+	case http.StatusNotFound:
+		return Session{}, ErrAuthUnsupported
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Session{}, fmt.Errorf("auth request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var s Session
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return Session{}, fmt.Errorf("decode session: %w", err)
+	}
+	if s.Username == "" {
+		s.Username = body["username"]
+	}
+	return s, nil
+}
+
+// SetToken sets the session token sent on future handshakes and auth
+// messages
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+// Authenticate re-authenticates the current connection with a new token,
+// e.g. after refreshing an expired session
+func (c *Client) Authenticate(token string) error {
+	c.SetToken(token)
+	return c.Send(ClientMessage{
+		Type:     "auth",
+		Username: c.Username,
+		Token:    token,
+	})
+}
+
+// ClaimContext claims the connected username, which must not be claimed
+// yet, and returns its first session. The connection stays authenticated
+// and later handshakes send the new token.
+func (c *Client) ClaimContext(ctx context.Context) (Session, error) {
+	ack, err := c.Request(ctx, ClientMessage{Type: "claim"})
+	if err != nil {
+		return Session{}, err
+	}
+	if ack.Session == nil {
+		return Session{}, errors.New("claim ack carried no session")
+	}
+	s := *ack.Session
+	if s.Username == "" {
+		s.Username = c.Username
+	}
+	c.SetToken(s.Token)
+	return s, nil
+}
+
+// authHeader returns the handshake headers carrying the current token
+func (c *Client) authHeader() (http.Header, string) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+
+	if token == "" {
+		return nil, ""
+	}
+	return http.Header{"Authorization": {"Bearer " + token}}, token
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff controls how the client redials after losing its connection
+type Backoff struct {
+	Initial     time.Duration // Delay before the first redial
+	Max         time.Duration // Upper bound on any single delay
+	Multiplier  float64       // Growth factor between attempts
+	Jitter      float64       // Fraction of the delay randomized (0-1)
+	MaxAttempts int           // Give up after this many attempts (0 = never, <0 = don't redial)
+}
+
+// DefaultBackoff is tuned for flaky home Wi-Fi: quick first retry, capped at 30s
+var DefaultBackoff = Backoff{
+	Initial:     500 * time.Millisecond,
+	Max:         30 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.5,
+	MaxAttempts: 0,
+}
+
+// Delay returns the wait before the given reconnect attempt (1-based)
+func (b Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(b.Initial)
+	for i := 1; i < attempt && d < float64(b.Max); i++ {
+		d *= b.Multiplier
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	// Spread redials so a server restart isn't hit by every client at once
+	if b.Jitter > 0 {
+		d -= d * b.Jitter * rand.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+// exhausted reports whether no further attempts are allowed
+func (b Backoff) exhausted(attempt int) bool {
+	return b.MaxAttempts != 0 && attempt > b.MaxAttempts
+}
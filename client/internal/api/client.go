@@ -1,53 +1,29 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// ServerMessage types from the server
-type ServerMessage struct {
-	Type         string             `json:"type"`
-	Username     string             `json:"username,omitempty"`
-	Player       *PlayerState       `json:"player,omitempty"`
-	Nits         int                `json:"nits,omitempty"`
-	Heat         int                `json:"heat,omitempty"`
-	Message      string             `json:"message,omitempty"`
-	From         string             `json:"from,omitempty"`
-	RouteId      string             `json:"routeId,omitempty"`
-	Route        *RouteState        `json:"route,omitempty"`
-	Routes       []RouteState       `json:"routes,omitempty"`
-	Intersection *IntersectionState `json:"intersection,omitempty"`
-	Poi          *IntersectionState `json:"poi,omitempty"`
-	Bids         []MarketOrder      `json:"bids,omitempty"`
-	Asks         []MarketOrder      `json:"asks,omitempty"`
-	OrderId      string             `json:"orderId,omitempty"`
-	Amount       int                `json:"amount,omitempty"`
-	Price        float64            `json:"price,omitempty"`
-	FromPoi      string             `json:"fromPoi,omitempty"`
-	PoiId        string             `json:"poiId,omitempty"`
-	Attacker     string             `json:"attacker,omitempty"`
-	NewController string            `json:"newController,omitempty"`
-	VisiblePlayers []VisiblePlayer  `json:"visiblePlayers,omitempty"`
-}
-
 // PlayerState from the server
 type PlayerState struct {
-	Username       string             `json:"username"`
-	Nits           int                `json:"nits"`
-	ProductionRate int                `json:"productionRate"`
-	Coordinates    Coordinates        `json:"coordinates"`
-	City           string             `json:"city"`
-	Region         string             `json:"region"`
-	Country        string             `json:"country"`
-	CreatedAt      int64              `json:"createdAt"`
-	Routes         []string           `json:"routes"`
-	Heat           int                `json:"heat"`
-	PoiInvestments map[string]int     `json:"poiInvestments"`
+	Username       string         `json:"username"`
+	Nits           int            `json:"nits"`
+	ProductionRate int            `json:"productionRate"`
+	Coordinates    Coordinates    `json:"coordinates"`
+	City           string         `json:"city"`
+	Region         string         `json:"region"`
+	Country        string         `json:"country"`
+	CreatedAt      int64          `json:"createdAt"`
+	Routes         []string       `json:"routes"`
+	Heat           int            `json:"heat"`
+	PoiInvestments map[string]int `json:"poiInvestments"`
 }
 
 // Coordinates for geographic position
@@ -86,7 +62,7 @@ type VisiblePlayer struct {
 	Username    string      `json:"username"`
 	Coordinates Coordinates `json:"coordinates"`
 	Heat        int         `json:"heat"`
-	Nits        int         `json:"nits,omitempty"`
+	Nits        *int        `json:"nits,omitempty"` // Only revealed at burning heat
 }
 
 // MarketOrder from the server
@@ -101,40 +77,93 @@ type MarketOrder struct {
 
 // ClientMessage to send to server
 type ClientMessage struct {
-	Type     string  `json:"type"`
-	Username string  `json:"username,omitempty"`
-	To       string  `json:"to,omitempty"`
-	RouteId  string  `json:"routeId,omitempty"`
-	Side     string  `json:"side,omitempty"`
-	Price    float64 `json:"price,omitempty"`
-	Amount   int     `json:"amount,omitempty"`
-	OrderId  string  `json:"orderId,omitempty"`
-	PoiId    string  `json:"poiId,omitempty"`
+	Type      string  `json:"type"`
+	RequestId string  `json:"requestId,omitempty"` // Echoed in the server's ack or error
+	Username  string  `json:"username,omitempty"`
+	To        string  `json:"to,omitempty"`
+	RouteId   string  `json:"routeId,omitempty"`
+	Side      string  `json:"side,omitempty"`
+	Price     float64 `json:"price,omitempty"`
+	Amount    int     `json:"amount,omitempty"`
+	OrderId   string  `json:"orderId,omitempty"`
+	PoiId     string  `json:"poiId,omitempty"`
+	Token     string  `json:"token,omitempty"` // Session token, on auth only
+}
+
+// ReconnectEvent reports a redial in progress after the connection dropped
+type ReconnectEvent struct {
+	Attempt int           // 1-based attempt number
+	Delay   time.Duration // Wait before this attempt
+	Err     error         // Why the connection (or the previous attempt) failed
 }
 
 // Client handles WebSocket communication with the foam server
 type Client struct {
-	conn     *websocket.Conn
-	URL      string
-	Username string
-	Messages chan ServerMessage
-	Errors   chan error
-	Done     chan struct{}
+	conn           *websocket.Conn
+	stop           chan struct{} // Closed when conn's pumps stop
+	mu             sync.Mutex    // Guards conn and stop, which are swapped on reconnect, and token
+	token          string        // Session token for a claimed username (see SetToken)
+	URL            string
+	Username       string
+	Backoff        Backoff       // Redial policy; MaxAttempts < 0 disables reconnecting
+	Heartbeat      Heartbeat     // Ping cadence and read/write deadlines
+	RequestTimeout time.Duration // Bounds Request calls whose context has no deadline
+	Recorder       *Recorder     // Records every frame both ways when set; set before Connect
+	Logger         *slog.Logger  // Connection lifecycle, and frames at debug level; set before Connect
+	Messages       chan Event
+	Errors         chan error
+	Reconnects     chan ReconnectEvent
+	Done           chan struct{}
+
+	// Inbound delivery queue between readPump and Messages
+	queueMu    sync.Mutex
+	queue      []Event
+	queueReady chan struct{}
+	stats      Stats
+	startOnce  sync.Once
+
+	// Outbound frames; writePump is the connection's only writer
+	outbound chan outboundFrame
+
+	// Requests awaiting their ack or error, by request id
+	requestSeq atomic.Uint64
+	pendingMu  sync.Mutex
+	pending    map[string]chan Event
+
+	// Heartbeat measurements, in UnixNano / nanoseconds
+	latency  atomic.Int64
+	lastSeen atomic.Int64
+	pingSent atomic.Int64
 }
 
 // NewClient creates a new API client
 func NewClient(baseURL, username string) *Client {
 	return &Client{
-		URL:      baseURL,
-		Username: username,
-		Messages: make(chan ServerMessage, 10),
-		Errors:   make(chan error, 1),
-		Done:     make(chan struct{}),
+		URL:            baseURL,
+		Username:       username,
+		Backoff:        DefaultBackoff,
+		Heartbeat:      DefaultHeartbeat,
+		RequestTimeout: DefaultRequestTimeout,
+		Messages:       make(chan Event, 10),
+		Errors:         make(chan error, 1),
+		Reconnects:     make(chan ReconnectEvent, 1),
+		Done:           make(chan struct{}),
+		queueReady:     make(chan struct{}, 1),
+		outbound:       make(chan outboundFrame, 64),
+		pending:        make(map[string]chan Event),
 	}
 }
 
 // Connect establishes WebSocket connection to the server
 func (c *Client) Connect() error {
+	c.startOnce.Do(func() {
+		go c.deliverPump()
+	})
+	return c.dial()
+}
+
+// dial opens a fresh connection, authenticates and starts reading from it
+func (c *Client) dial() error {
 	u, err := url.Parse(c.URL)
 	if err != nil {
 		return fmt.Errorf("invalid URL: %w", err)
@@ -146,33 +175,37 @@ func (c *Client) Connect() error {
 		HandshakeTimeout: 10 * time.Second,
 	}
 
-	conn, _, err := dialer.Dial(u.String(), nil)
+	header, token := c.authHeader()
+	c.log().Info("dialing", "url", u.String(), "token", token != "")
+	conn, _, err := dialer.Dial(u.String(), header)
 	if err != nil {
+		c.log().Warn("dial failed", "err", err)
 		return fmt.Errorf("connection failed: %w", err)
 	}
 
-	c.conn = conn
-
-	go c.readPump()
-
-	return c.Send(ClientMessage{
+	// Authenticate before anything else is written or read so the server's
+	// fresh connected/state messages come first on a resumed session
+	if err := c.writeMessage(conn, ClientMessage{
 		Type:     "auth",
 		Username: c.Username,
-	})
-}
-
-// Send sends a message to the server
-func (c *Client) Send(msg ClientMessage) error {
-	if c.conn == nil {
-		return fmt.Errorf("not connected")
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
+		Token:    token,
+	}); err != nil {
+		conn.Close()
+		c.log().Warn("auth write failed", "err", err)
 		return err
 	}
 
-	return c.conn.WriteMessage(websocket.TextMessage, data)
+	stop := make(chan struct{})
+	c.mu.Lock()
+	c.conn, c.stop = conn, stop
+	c.mu.Unlock()
+	c.log().Info("connected", "remote", conn.RemoteAddr().String())
+
+	c.touch(conn)
+	go c.readPump(conn, stop)
+	go c.writePump(conn, stop)
+
+	return nil
 }
 
 // RequestRoute sends a route request to another player
@@ -199,6 +232,14 @@ func (c *Client) RejectRoute(routeId string) error {
 	})
 }
 
+// WithdrawRoute takes back a route request we sent
+func (c *Client) WithdrawRoute(routeId string) error {
+	return c.Send(ClientMessage{
+		Type:    "withdraw_route",
+		RouteId: routeId,
+	})
+}
+
 // PlaceOrder places a market order
 func (c *Client) PlaceOrder(side string, price float64, amount int) error {
 	return c.Send(ClientMessage{
@@ -237,41 +278,124 @@ func (c *Client) UpgradeRoute(routeId string) error {
 // Close closes the connection
 func (c *Client) Close() error {
 	close(c.Done)
+	c.log().Info("closed")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.conn != nil {
 		return c.conn.Close()
 	}
 	return nil
 }
 
-// readPump reads messages from WebSocket
-func (c *Client) readPump() {
-	defer func() {
-		c.conn.Close()
-	}()
+// closed reports whether Close has been called
+func (c *Client) closed() bool {
+	select {
+	case <-c.Done:
+		return true
+	default:
+		return false
+	}
+}
+
+// dropConn closes conn and forgets it if it is still the active connection
+func (c *Client) dropConn(conn *websocket.Conn) {
+	conn.Close()
 
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+}
+
+// readPump reads messages from WebSocket; closing stop ends the
+// connection's writePump
+func (c *Client) readPump(conn *websocket.Conn, stop chan struct{}) {
 	for {
 		select {
 		case <-c.Done:
+			conn.Close()
+			close(stop)
 			return
 		default:
-			_, message, err := c.conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
-				select {
-				case c.Errors <- err:
-				default:
+				// Retire this connection's writer before redialing so only
+				// one write pump ever drains the outbound queue
+				c.dropConn(conn)
+				close(stop)
+				if !c.closed() {
+					c.log().Warn("connection lost", "err", err)
+					c.reconnect(err)
 				}
 				return
 			}
 
-			var msg ServerMessage
-			if err := json.Unmarshal(message, &msg); err != nil {
+			c.touch(conn)
+			if c.Recorder != nil {
+				c.Recorder.Record(DirIn, message)
+			}
+			c.logFrame("received", message)
+
+			ev, err := Decode(message)
+			if err != nil {
+				c.log().Warn("unreadable frame", "err", err, "frame", truncate(string(message)))
+				c.recordParseError(err)
+				continue
+			}
+
+			if _, ok := ev.(PongEvent); ok {
+				c.recordPong()
 				continue
 			}
 
-			select {
-			case c.Messages <- msg:
-			default:
+			c.recordReceived()
+			if c.resolve(ev) {
+				continue
 			}
+
+			c.enqueue(ev)
 		}
 	}
 }
+
+// reconnect redials with jittered exponential backoff until it succeeds,
+// the client is closed, or the backoff policy gives up
+func (c *Client) reconnect(cause error) {
+	for attempt := 1; !c.Backoff.exhausted(attempt); attempt++ {
+		delay := c.Backoff.Delay(attempt)
+		c.log().Info("reconnecting", "attempt", attempt, "delay", delay, "err", cause)
+		c.notifyReconnect(ReconnectEvent{Attempt: attempt, Delay: delay, Err: cause})
+
+		select {
+		case <-c.Done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := c.dial(); err != nil {
+			cause = err
+			continue
+		}
+		return
+	}
+
+	c.log().Error("giving up reconnecting", "err", cause)
+	select {
+	case c.Errors <- fmt.Errorf("connection lost: %w", cause):
+	default:
+	}
+}
+
+// notifyReconnect publishes ev, replacing any attempt nobody has read yet
+func (c *Client) notifyReconnect(ev ReconnectEvent) {
+	select {
+	case <-c.Reconnects:
+	default:
+	}
+	select {
+	case c.Reconnects <- ev:
+	default:
+	}
+}
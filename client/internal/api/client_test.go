@@ -0,0 +1,550 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/fakeserver"
+)
+
+const waitTimeout = 5 * time.Second
+
+// connect dials srv as username and consumes the snapshot up to pois
+func connect(t *testing.T, srv *fakeserver.Server, username string) *api.Client {
+	t.Helper()
+	c := api.NewClient(srv.URL, username)
+	c.Backoff.Initial = 10 * time.Millisecond
+	if err := c.Connect(); err != nil {
+		t.Fatalf("connect %s: %v", username, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	next[api.PoisEvent](t, c)
+	return c
+}
+
+// next returns the next event of type T, skipping any others
+func next[T api.Event](t *testing.T, c *api.Client) T {
+	t.Helper()
+	timeout := time.After(waitTimeout)
+	for {
+		select {
+		case ev := <-c.Messages:
+			if ev, ok := ev.(T); ok {
+				return ev
+			}
+		case err := <-c.Errors:
+			t.Fatalf("%s: client error: %v", c.Username, err)
+		case <-timeout:
+			var zero T
+			t.Fatalf("%s: no %s message within %v", c.Username, zero.Type(), waitTimeout)
+		}
+	}
+}
+
+// testContext bounds a test's requests
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// crossing places four players so that al-bob and cy-dee cross
+func crossing(srv *fakeserver.Server) {
+	for _, p := range []api.PlayerState{
+		{Username: "al", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 0, Lng: 0}},
+		{Username: "bob", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 1, Lng: 1}},
+		{Username: "cy", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 0, Lng: 1}},
+		{Username: "dee", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 1, Lng: 0}},
+	} {
+		srv.AddPlayer(p)
+	}
+	srv.AddRoute("al", "bob")
+	srv.AddRoute("cy", "dee")
+}
+
+func TestSnapshot(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	crossing(srv)
+
+	c := api.NewClient(srv.URL, "al")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if ev := next[api.ConnectedEvent](t, c); ev.Username != "al" {
+		t.Errorf("connected as %q, want al", ev.Username)
+	}
+	if ev := next[api.StateEvent](t, c); ev.Player.Nits != 100 || len(ev.Player.Routes) != 1 {
+		t.Errorf("state = %+v, want 100 nits and 1 route", ev.Player)
+	}
+	if ev := next[api.RoutesEvent](t, c); len(ev.Routes) != 1 || ev.Routes[0].PlayerB != "bob" {
+		t.Errorf("routes = %+v, want one to bob", ev.Routes)
+	}
+	ev := next[api.PoisEvent](t, c)
+	if len(ev.Pois) != 1 {
+		t.Fatalf("got %d POIs, want the one where the routes cross", len(ev.Pois))
+	}
+	if at := ev.Pois[0].Coordinates; at.Lat != 0.5 || at.Lng != 0.5 {
+		t.Errorf("POI at %+v, want 0.5,0.5", at)
+	}
+}
+
+func TestRequestAckAndError(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	c := connect(t, srv, "al")
+	ctx := testContext(t)
+
+	ack, err := c.PlaceOrderContext(ctx, "bid", 1.5, 10)
+	if err != nil {
+		t.Fatalf("place order: %v", err)
+	}
+	if ack.OrderId == "" {
+		t.Error("place_order ack has no order id")
+	}
+
+	_, err = c.InvestPoiContext(ctx, "poi-nope", 10)
+	var serr *api.ServerError
+	if !errors.As(err, &serr) || serr.Message != "POI not found" {
+		t.Errorf("invest in unknown POI: err = %v, want ServerError", err)
+	}
+
+	_, err = c.InvestPoiContext(ctx, "poi-nope", 1000)
+	if !errors.As(err, &serr) || serr.Message != "Insufficient nits" {
+		t.Errorf("invest beyond our nits: err = %v, want Insufficient nits", err)
+	}
+}
+
+func TestStatsCountReplies(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	c := connect(t, srv, "al")
+
+	before := c.Stats()
+	if _, err := c.InvestPoiContext(testContext(t), "poi-nope", 10); err == nil {
+		t.Fatal("invest in unknown POI succeeded")
+	}
+	after := c.Stats()
+	if after.Received != before.Received+1 || after.Delivered != before.Delivered {
+		t.Errorf("received %d, delivered %d; want the error counted as received (%d) but not delivered (%d)",
+			after.Received, after.Delivered, before.Received+1, before.Delivered)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := fakeserver.New()
+	srv.Intercept = func(username string, msg api.ClientMessage) bool {
+		return msg.Type == "upgrade_route" // Never answered
+	}
+	defer srv.Close()
+	c := connect(t, srv, "al")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.UpgradeRouteContext(ctx, "r1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRouteLifecycle(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	al := connect(t, srv, "al")
+	bob := connect(t, srv, "bob")
+	ctx := testContext(t)
+
+	ack, err := al.RequestRouteContext(ctx, "bob")
+	if err != nil {
+		t.Fatalf("request route: %v", err)
+	}
+	req := next[api.RouteRequestEvent](t, bob)
+	if req.From != "al" || req.RouteId != ack.RouteId {
+		t.Fatalf("bob got %+v, want a request from al for %s", req, ack.RouteId)
+	}
+
+	if _, err := al.AcceptRouteContext(ctx, req.RouteId); err == nil {
+		t.Error("al accepted its own request")
+	}
+	if _, err := bob.AcceptRouteContext(ctx, req.RouteId); err != nil {
+		t.Fatalf("accept route: %v", err)
+	}
+	for _, c := range []*api.Client{al, bob} {
+		ev := next[api.RouteAcceptedEvent](t, c)
+		if ev.Route.PlayerA != "al" || ev.Route.PlayerB != "bob" || ev.Route.Status != "active" {
+			t.Errorf("%s: accepted route %+v, want active al-bob", c.Username, ev.Route)
+		}
+	}
+
+	ack, err = bob.RequestRouteContext(ctx, "al")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next[api.RouteRequestEvent](t, al)
+
+	// A client that connects now finds the pending request in its snapshot
+	for _, who := range []string{"al", "bob"} {
+		c := api.NewClient(srv.URL, who)
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		ev := next[api.RoutesEvent](t, c)
+		if who == "al" && (len(ev.Requests) != 1 || ev.Requests[0].From != "bob" || ev.Requests[0].RouteId != ack.RouteId) {
+			t.Errorf("al's snapshot requests = %+v, want bob's %s", ev.Requests, ack.RouteId)
+		}
+		if who == "bob" && (len(ev.Outbound) != 1 || ev.Outbound[0].To != "al" || ev.Outbound[0].RouteId != ack.RouteId) {
+			t.Errorf("bob's snapshot outbound = %+v, want %s to al", ev.Outbound, ack.RouteId)
+		}
+	}
+
+	if _, err := bob.WithdrawRouteContext(ctx, ack.RouteId); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	if ev := next[api.RouteWithdrawnEvent](t, al); ev.RouteId != ack.RouteId {
+		t.Errorf("withdrawn %s, want %s", ev.RouteId, ack.RouteId)
+	}
+}
+
+func TestContest(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	crossing(srv)
+	al := connect(t, srv, "al")
+	cy := connect(t, srv, "cy")
+	ctx := testContext(t)
+
+	srv.Tick() // Not needed for the contest, but ticks mustn't confuse it
+	next[api.TickEvent](t, al)
+
+	pois := srv.Pois()
+	if len(pois) != 1 {
+		t.Fatalf("got %d POIs, want the one where the routes cross", len(pois))
+	}
+	poi := pois[0].Id
+	if _, err := al.InvestPoiContext(ctx, poi, 10); err != nil {
+		t.Fatalf("al invest: %v", err)
+	}
+	if ev := next[api.PoiUpdateEvent](t, al); ev.Poi.Controller != "al" {
+		t.Fatalf("controller = %q after al's investment, want al", ev.Poi.Controller)
+	}
+
+	if _, err := cy.InvestPoiContext(ctx, poi, 10); err != nil {
+		t.Fatalf("cy invest: %v", err)
+	}
+	ev := next[api.PoiContestEvent](t, al)
+	if ev.Attacker != "cy" || ev.Amount != 10 || ev.NewController == nil || *ev.NewController != "al" {
+		t.Errorf("contest = %+v, want cy's 10 with al still in control (ties keep the controller)", ev)
+	}
+
+	if _, err := cy.InvestPoiContext(ctx, poi, 1); err != nil {
+		t.Fatalf("cy invest: %v", err)
+	}
+	ev = next[api.PoiContestEvent](t, al)
+	if ev.NewController == nil || *ev.NewController != "cy" {
+		t.Errorf("contest = %+v, want cy in control", ev)
+	}
+
+	p, _ := srv.Player("al")
+	want := 100 + 1 - 10 // One tick, one investment
+	if p.Nits != want {
+		t.Errorf("al has %d nits, want %d", p.Nits, want)
+	}
+}
+
+func TestOrderFill(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	al := connect(t, srv, "al")
+	bob := connect(t, srv, "bob")
+	ctx := testContext(t)
+
+	ask, err := al.PlaceOrderContext(ctx, "ask", 1.5, 10)
+	if err != nil {
+		t.Fatalf("ask: %v", err)
+	}
+	snap, err := api.FetchMarket(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("fetch market: %v", err)
+	}
+	if len(snap.Asks) != 1 || snap.Asks[0].Id != ask.OrderId {
+		t.Fatalf("asks = %+v, want al's order", snap.Asks)
+	}
+
+	bid, err := bob.PlaceOrderContext(ctx, "bid", 2, 4)
+	if err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+	if ev := next[api.OrderFilledEvent](t, bob); ev.OrderId != bid.OrderId || ev.Amount != 4 || ev.Price != 1.5 || ev.Side != "bid" {
+		t.Errorf("bob's fill = %+v, want 4 @ 1.5 on %s", ev, bid.OrderId)
+	}
+	if ev := next[api.OrderFilledEvent](t, al); ev.OrderId != ask.OrderId || ev.Amount != 4 || ev.Side != "ask" {
+		t.Errorf("al's fill = %+v, want 4 on %s", ev, ask.OrderId)
+	}
+
+	snap, err = api.FetchMarket(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Asks) != 1 || snap.Asks[0].Amount != 6 || snap.LastPrice != 1.5 {
+		t.Errorf("book = %+v, want 6 left at 1.5", snap)
+	}
+
+	if _, err := al.CancelOrderContext(ctx, ask.OrderId); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if p, _ := srv.Player("al"); p.Nits != 96 {
+		t.Errorf("al has %d nits after cancelling, want 96 (escrow returned)", p.Nits)
+	}
+	if _, err := al.CancelOrderContext(ctx, ask.OrderId); err == nil {
+		t.Error("cancelled the same order twice")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	c := connect(t, srv, "al")
+
+	srv.Disconnect("al")
+	select {
+	case <-c.Reconnects:
+	case <-time.After(waitTimeout):
+		t.Fatal("no reconnect attempt")
+	}
+	next[api.ConnectedEvent](t, c)
+	next[api.PoisEvent](t, c)
+
+	if _, err := c.PlaceOrderContext(testContext(t), "bid", 1, 1); err != nil {
+		t.Errorf("request after reconnecting: %v", err)
+	}
+}
+
+func TestSendWhileReconnecting(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	c := api.NewClient(srv.URL, "al")
+	c.Backoff.Initial = time.Minute // Stay reconnecting for the test
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	next[api.PoisEvent](t, c)
+
+	srv.Disconnect("al")
+	select {
+	case <-c.Reconnects:
+	case <-time.After(waitTimeout):
+		t.Fatal("no reconnect attempt")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.PlaceOrder("bid", 1, 1) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, api.ErrNotConnected) {
+			t.Errorf("err = %v, want ErrNotConnected", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("Send blocked while reconnecting")
+	}
+}
+
+func TestMalformedFrames(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	c := connect(t, srv, "al")
+
+	srv.SendRaw("al", []byte(`{"type":"from_the_future"}`))
+	srv.SendRaw("al", []byte(`{"type":"tick","nits":5}`))
+	srv.SendRaw("al", []byte(`not json`))
+	srv.Send("al", api.HeatUpdateEvent{Heat: 42})
+
+	if ev := next[api.HeatUpdateEvent](t, c); ev.Heat != 42 {
+		t.Errorf("heat = %d, want 42", ev.Heat)
+	}
+	stats := c.Stats()
+	if stats.ParseErrors != 3 || stats.UnknownTypes != 1 {
+		t.Errorf("stats = %+v, want 3 parse errors, 1 unknown type", stats)
+	}
+}
+
+func TestClaimedUsername(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	ctx := testContext(t)
+
+	session, err := api.Claim(ctx, srv.URL, "al")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := api.Claim(ctx, srv.URL, "al"); !errors.Is(err, api.ErrAlreadyClaimed) {
+		t.Errorf("second claim: err = %v, want ErrAlreadyClaimed", err)
+	}
+
+	anon := api.NewClient(srv.URL, "al")
+	if err := anon.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer anon.Close()
+	if ev := next[api.ErrorEvent](t, anon); ev.Code != api.CodeAuthRequired {
+		t.Errorf("without a token: %+v, want %s", ev, api.CodeAuthRequired)
+	}
+
+	c := api.NewClient(srv.URL, "al")
+	c.SetToken(session.Token)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	next[api.PoisEvent](t, c)
+
+	srv.ExpireSession("al")
+	srv.Tick()
+	if ev := next[api.ErrorEvent](t, c); ev.Code != api.CodeAuthExpired {
+		t.Errorf("live session after expiry: %+v, want %s", ev, api.CodeAuthExpired)
+	}
+	expired := api.NewClient(srv.URL, "al")
+	expired.SetToken(session.Token)
+	if err := expired.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer expired.Close()
+	if ev := next[api.ErrorEvent](t, expired); ev.Code != api.CodeAuthExpired {
+		t.Errorf("expired token: %+v, want %s", ev, api.CodeAuthExpired)
+	}
+
+	refreshed, err := api.Refresh(ctx, srv.URL, session)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if err := expired.Authenticate(refreshed.Token); err != nil {
+		t.Fatal(err)
+	}
+	next[api.PoisEvent](t, expired)
+}
+
+func TestRecording(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	ctx := testContext(t)
+
+	session, err := api.Claim(ctx, srv.URL, "al")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	var buf bytes.Buffer
+	rec := api.NewRecorder(&buf)
+	c := api.NewClient(srv.URL, "al")
+	c.SetToken(session.Token)
+	c.Recorder = rec
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	next[api.PoisEvent](t, c)
+	srv.SendRaw("al", []byte("not json"))
+	srv.Send("al", api.HeatUpdateEvent{Heat: 42})
+	next[api.HeatUpdateEvent](t, c)
+	c.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), session.Token) {
+		t.Error("recording contains the session token")
+	}
+	frames, err := api.ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, f := range frames {
+		if f.Dir == api.DirIn && f.Raw == "" {
+			ev, err := api.Decode(f.Bytes())
+			if err != nil {
+				t.Fatalf("decode %s: %v", f.Bytes(), err)
+			}
+			types = append(types, ev.Type())
+		}
+	}
+	want := []string{"connected", "state", "routes", "pois"}
+	if len(types) < len(want) || !slices.Equal(types[:len(want)], want) {
+		t.Errorf("inbound frames = %v, want %v first", types, want)
+	}
+	if len(frames) == 0 || frames[0].Dir != api.DirOut {
+		t.Errorf("first frame should be the outbound auth")
+	}
+	raw := slices.IndexFunc(frames, func(f api.Frame) bool { return f.Raw == "not json" })
+	if raw < 0 {
+		t.Error("malformed frame not kept raw")
+	}
+}
+
+func TestClaimExistingPlayer(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	srv.AddPlayer(api.PlayerState{Username: "al", Nits: 100, ProductionRate: 1})
+	ctx := testContext(t)
+
+	if _, err := api.Claim(ctx, srv.URL, "al"); !errors.Is(err, api.ErrPlayerExists) {
+		t.Fatalf("HTTP claim of a playing username: err = %v, want ErrPlayerExists", err)
+	}
+
+	owner := connect(t, srv, "al")
+	other := connect(t, srv, "al")
+	session, err := owner.ClaimContext(ctx)
+	if err != nil {
+		t.Fatalf("claim over the connection: %v", err)
+	}
+	if session.Token == "" || session.Username != "al" {
+		t.Errorf("session = %+v", session)
+	}
+	if ev := next[api.ErrorEvent](t, other); ev.Code != api.CodeAuthRequired {
+		t.Errorf("other connection: %+v, want %s", ev, api.CodeAuthRequired)
+	}
+	if _, err := owner.ClaimContext(ctx); err == nil {
+		t.Error("second claim succeeded")
+	}
+}
+
+func TestUnauthenticatedSeesNothing(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	ctx := testContext(t)
+
+	session, err := api.Claim(ctx, srv.URL, "al")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	owner := api.NewClient(srv.URL, "al")
+	owner.SetToken(session.Token)
+	if err := owner.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Close()
+	next[api.PoisEvent](t, owner)
+
+	intruder := api.NewClient(srv.URL, "al")
+	if err := intruder.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer intruder.Close()
+	if ev := next[api.ErrorEvent](t, intruder); ev.Code != api.CodeAuthRequired {
+		t.Fatalf("intruder: %+v, want %s", ev, api.CodeAuthRequired)
+	}
+
+	srv.Tick()
+	next[api.TickEvent](t, owner)
+	srv.Send("al", api.HeatUpdateEvent{Heat: 1}) // Scripted frames reach every connection
+	next[api.HeatUpdateEvent](t, intruder)
+	if n := intruder.Stats().Delivered; n != 2 {
+		t.Errorf("intruder got %d messages, want only its auth error and the scripted frame", n)
+	}
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Event is one decoded server message. Each wire "type" maps to exactly
+// one concrete Event type, so fields are only present where the protocol
+// defines them.
+type Event interface {
+	// Type returns the wire "type" discriminator
+	Type() string
+	// Dispatch calls the Handler method for this event's concrete type
+	Dispatch(h Handler)
+}
+
+// Handler receives decoded events; implementing it guarantees at compile
+// time that every server message type is accounted for
+type Handler interface {
+	OnConnected(ConnectedEvent)
+	OnState(StateEvent)
+	OnTick(TickEvent)
+	OnError(ErrorEvent)
+	OnAck(AckEvent)
+	OnRouteRequest(RouteRequestEvent)
+	OnRouteAccepted(RouteAcceptedEvent)
+	OnRouteRejected(RouteRejectedEvent)
+	OnRouteWithdrawn(RouteWithdrawnEvent)
+	OnRoutes(RoutesEvent)
+	OnPois(PoisEvent)
+	OnIntersectionCreated(IntersectionCreatedEvent)
+	OnMarketUpdate(MarketUpdateEvent)
+	OnOrderFilled(OrderFilledEvent)
+	OnHeatUpdate(HeatUpdateEvent)
+	OnPoiUpdate(PoiUpdateEvent)
+	OnPoiContest(PoiContestEvent)
+	OnTollReceived(TollReceivedEvent)
+	OnVisibilityUpdate(VisibilityUpdateEvent)
+}
+
+// NopHandler ignores every event; embed it to handle only a subset
+type NopHandler struct{}
+
+func (NopHandler) OnConnected(ConnectedEvent)                     {}
+func (NopHandler) OnState(StateEvent)                             {}
+func (NopHandler) OnTick(TickEvent)                               {}
+func (NopHandler) OnError(ErrorEvent)                             {}
+func (NopHandler) OnAck(AckEvent)                                 {}
+func (NopHandler) OnRouteRequest(RouteRequestEvent)               {}
+func (NopHandler) OnRouteAccepted(RouteAcceptedEvent)             {}
+func (NopHandler) OnRouteRejected(RouteRejectedEvent)             {}
+func (NopHandler) OnRouteWithdrawn(RouteWithdrawnEvent)           {}
+func (NopHandler) OnRoutes(RoutesEvent)                           {}
+func (NopHandler) OnPois(PoisEvent)                               {}
+func (NopHandler) OnIntersectionCreated(IntersectionCreatedEvent) {}
+func (NopHandler) OnMarketUpdate(MarketUpdateEvent)               {}
+func (NopHandler) OnOrderFilled(OrderFilledEvent)                 {}
+func (NopHandler) OnHeatUpdate(HeatUpdateEvent)                   {}
+func (NopHandler) OnPoiUpdate(PoiUpdateEvent)                     {}
+func (NopHandler) OnPoiContest(PoiContestEvent)                   {}
+func (NopHandler) OnTollReceived(TollReceivedEvent)               {}
+func (NopHandler) OnVisibilityUpdate(VisibilityUpdateEvent)       {}
+
+// ConnectedEvent confirms authentication
+type ConnectedEvent struct {
+	Username string `json:"username"`
+}
+
+// StateEvent carries a full snapshot of our player
+type StateEvent struct {
+	Player PlayerState `json:"player"`
+}
+
+// TickEvent is the periodic production tick
+type TickEvent struct {
+	Nits int `json:"nits"`
+	Heat int `json:"heat"`
+}
+
+// ErrorEvent reports a rejected action or protocol problem
+type ErrorEvent struct {
+	Message   string `json:"message"`
+	RequestId string `json:"requestId,omitempty"` // Set when answering one of our requests
+	Code      string `json:"code,omitempty"`      // CodeAuthRequired or CodeAuthExpired, if set
+}
+
+// AckEvent confirms that one of our requests succeeded
+type AckEvent struct {
+	RequestId string   `json:"requestId"`
+	OrderId   string   `json:"orderId,omitempty"` // Set for place_order
+	RouteId   string   `json:"routeId,omitempty"` // Set for request_route
+	Session   *Session `json:"session,omitempty"` // Set for claim
+}
+
+// RouteRequestEvent is an inbound request to open a route
+type RouteRequestEvent struct {
+	From    string `json:"from"`
+	RouteId string `json:"routeId"`
+}
+
+// RouteAcceptedEvent announces a newly established route
+type RouteAcceptedEvent struct {
+	RouteId string     `json:"routeId"`
+	Route   RouteState `json:"route"`
+}
+
+// RouteRejectedEvent announces a declined route request, ours or to us
+type RouteRejectedEvent struct {
+	RouteId string `json:"routeId"`
+}
+
+// RouteWithdrawnEvent announces a route request taken back by its sender
+type RouteWithdrawnEvent struct {
+	RouteId string `json:"routeId"`
+}
+
+// RoutesEvent replaces our full route list
+type RoutesEvent struct {
+	Routes   []RouteState        `json:"routes"`
+	Requests []RouteRequestEvent `json:"requests"` // Inbound requests awaiting our answer; nil from older servers
+	Outbound []OutboundRequest   `json:"outbound"` // Requests we sent, awaiting an answer; nil from older servers
+}
+
+// OutboundRequest is a route request we sent that hasn't been answered
+type OutboundRequest struct {
+	To      string `json:"to"`
+	RouteId string `json:"routeId"`
+}
+
+// PoisEvent replaces the POIs we know about. It follows RoutesEvent after
+// authenticating and ends the initial snapshot.
+type PoisEvent struct {
+	Pois []IntersectionState `json:"pois"`
+}
+
+// IntersectionCreatedEvent announces a new POI on one of our routes
+type IntersectionCreatedEvent struct {
+	Intersection IntersectionState `json:"intersection"`
+}
+
+// MarketUpdateEvent replaces the visible order book
+type MarketUpdateEvent struct {
+	Bids []MarketOrder `json:"bids"`
+	Asks []MarketOrder `json:"asks"`
+}
+
+// OrderFilledEvent reports a (partial) fill of one of our orders
+type OrderFilledEvent struct {
+	OrderId string  `json:"orderId"`
+	Amount  int     `json:"amount"`
+	Price   float64 `json:"price"`
+	Side    string  `json:"side,omitempty"` // "bid" or "ask"; absent from older servers
+}
+
+// HeatUpdateEvent reports a heat change outside the regular tick
+type HeatUpdateEvent struct {
+	Heat int `json:"heat"`
+}
+
+// PoiUpdateEvent carries the latest state of a POI
+type PoiUpdateEvent struct {
+	Poi IntersectionState `json:"poi"`
+}
+
+// PoiContestEvent reports an investment that contests a POI
+type PoiContestEvent struct {
+	PoiId         string  `json:"poiId"`
+	Attacker      string  `json:"attacker"`
+	Amount        int     `json:"amount"`
+	NewController *string `json:"newController"` // nil when nobody holds the POI
+}
+
+// TollReceivedEvent reports toll income from a controlled POI
+type TollReceivedEvent struct {
+	Amount  int    `json:"amount"`
+	FromPoi string `json:"fromPoi"`
+}
+
+// VisibilityUpdateEvent replaces the set of players we can see
+type VisibilityUpdateEvent struct {
+	VisiblePlayers []VisiblePlayer `json:"visiblePlayers"`
+}
+
+// PongEvent answers our heartbeat ping. The Client consumes it to measure
+// latency; it is never delivered on Messages.
+type PongEvent struct{}
+
+func (ConnectedEvent) Type() string           { return "connected" }
+func (StateEvent) Type() string               { return "state" }
+func (TickEvent) Type() string                { return "tick" }
+func (ErrorEvent) Type() string               { return "error" }
+func (AckEvent) Type() string                 { return "ack" }
+func (RouteRequestEvent) Type() string        { return "route_request" }
+func (RouteAcceptedEvent) Type() string       { return "route_accepted" }
+func (RouteRejectedEvent) Type() string       { return "route_rejected" }
+func (RouteWithdrawnEvent) Type() string      { return "route_withdrawn" }
+func (RoutesEvent) Type() string              { return "routes" }
+func (PoisEvent) Type() string                { return "pois" }
+func (IntersectionCreatedEvent) Type() string { return "intersection_created" }
+func (MarketUpdateEvent) Type() string        { return "market_update" }
+func (OrderFilledEvent) Type() string         { return "order_filled" }
+func (HeatUpdateEvent) Type() string          { return "heat_update" }
+func (PoiUpdateEvent) Type() string           { return "poi_update" }
+func (PoiContestEvent) Type() string          { return "poi_contest" }
+func (TollReceivedEvent) Type() string        { return "toll_received" }
+func (VisibilityUpdateEvent) Type() string    { return "visibility_update" }
+func (PongEvent) Type() string                { return "pong" }
+
+func (e ConnectedEvent) Dispatch(h Handler)           { h.OnConnected(e) }
+func (e StateEvent) Dispatch(h Handler)               { h.OnState(e) }
+func (e TickEvent) Dispatch(h Handler)                { h.OnTick(e) }
+func (e ErrorEvent) Dispatch(h Handler)               { h.OnError(e) }
+func (e AckEvent) Dispatch(h Handler)                 { h.OnAck(e) }
+func (e RouteRequestEvent) Dispatch(h Handler)        { h.OnRouteRequest(e) }
+func (e RouteAcceptedEvent) Dispatch(h Handler)       { h.OnRouteAccepted(e) }
+func (e RouteRejectedEvent) Dispatch(h Handler)       { h.OnRouteRejected(e) }
+func (e RouteWithdrawnEvent) Dispatch(h Handler)      { h.OnRouteWithdrawn(e) }
+func (e RoutesEvent) Dispatch(h Handler)              { h.OnRoutes(e) }
+func (e PoisEvent) Dispatch(h Handler)                { h.OnPois(e) }
+func (e IntersectionCreatedEvent) Dispatch(h Handler) { h.OnIntersectionCreated(e) }
+func (e MarketUpdateEvent) Dispatch(h Handler)        { h.OnMarketUpdate(e) }
+func (e OrderFilledEvent) Dispatch(h Handler)         { h.OnOrderFilled(e) }
+func (e HeatUpdateEvent) Dispatch(h Handler)          { h.OnHeatUpdate(e) }
+func (e PoiUpdateEvent) Dispatch(h Handler)           { h.OnPoiUpdate(e) }
+func (e PoiContestEvent) Dispatch(h Handler)          { h.OnPoiContest(e) }
+func (e TollReceivedEvent) Dispatch(h Handler)        { h.OnTollReceived(e) }
+func (e VisibilityUpdateEvent) Dispatch(h Handler)    { h.OnVisibilityUpdate(e) }
+func (e PongEvent) Dispatch(h Handler)                {}
+
+// eventSpec describes how to decode one message type
+type eventSpec struct {
+	decode   func([]byte) (Event, error)
+	required []string // JSON keys that must be present
+}
+
+// eventSpecs maps each wire type to its decoder; keep in sync with
+// ServerMessage in server/src/types.ts
+var eventSpecs = map[string]eventSpec{
+	"connected":            {decodeAs[ConnectedEvent], []string{"username"}},
+	"state":                {decodeAs[StateEvent], []string{"player"}},
+	"tick":                 {decodeAs[TickEvent], []string{"nits", "heat"}},
+	"error":                {decodeAs[ErrorEvent], []string{"message"}},
+	"ack":                  {decodeAs[AckEvent], []string{"requestId"}},
+	"route_request":        {decodeAs[RouteRequestEvent], []string{"from", "routeId"}},
+	"route_accepted":       {decodeAs[RouteAcceptedEvent], []string{"routeId", "route"}},
+	"route_rejected":       {decodeAs[RouteRejectedEvent], []string{"routeId"}},
+	"route_withdrawn":      {decodeAs[RouteWithdrawnEvent], []string{"routeId"}},
+	"routes":               {decodeAs[RoutesEvent], []string{"routes"}},
+	"pois":                 {decodeAs[PoisEvent], []string{"pois"}},
+	"intersection_created": {decodeAs[IntersectionCreatedEvent], []string{"intersection"}},
+	"market_update":        {decodeAs[MarketUpdateEvent], []string{"bids", "asks"}},
+	"order_filled":         {decodeAs[OrderFilledEvent], []string{"orderId", "amount", "price"}},
+	"heat_update":          {decodeAs[HeatUpdateEvent], []string{"heat"}},
+	"poi_update":           {decodeAs[PoiUpdateEvent], []string{"poi"}},
+	"poi_contest":          {decodeAs[PoiContestEvent], []string{"poiId", "attacker", "amount"}},
+	"toll_received":        {decodeAs[TollReceivedEvent], []string{"amount", "fromPoi"}},
+	"visibility_update":    {decodeAs[VisibilityUpdateEvent], []string{"visiblePlayers"}},
+	"pong":                 {decodeAs[PongEvent], nil},
+}
+
+// ErrUnknownType is wrapped by UnknownTypeError for errors.Is checks
+var ErrUnknownType = errors.New("unknown message type")
+
+// UnknownTypeError is returned by Decode for a type this client doesn't know
+type UnknownTypeError struct {
+	Type string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown message type %q", e.Type)
+}
+
+func (e *UnknownTypeError) Unwrap() error {
+	return ErrUnknownType
+}
+
+// Decode parses a raw server frame into its concrete Event type
+func Decode(data []byte) (Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	var typ string
+	if raw, ok := fields["type"]; ok {
+		if err := json.Unmarshal(raw, &typ); err != nil {
+			return nil, fmt.Errorf("invalid message type: %w", err)
+		}
+	}
+	if typ == "" {
+		return nil, fmt.Errorf("invalid message: missing type")
+	}
+
+	spec, ok := eventSpecs[typ]
+	if !ok {
+		return nil, &UnknownTypeError{Type: typ}
+	}
+
+	for _, key := range spec.required {
+		if _, ok := fields[key]; !ok {
+			return nil, fmt.Errorf("%s message missing %q", typ, key)
+		}
+	}
+
+	ev, err := spec.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s message: %w", typ, err)
+	}
+	return ev, nil
+}
+
+// decodeAs unmarshals data into the event type T
+func decodeAs[T Event](data []byte) (Event, error) {
+	var ev T
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat controls liveness checking on the connection
+type Heartbeat struct {
+	Interval     time.Duration // How often to send a ping (0 disables pings)
+	ReadTimeout  time.Duration // Drop the connection after this long without any frame
+	WriteTimeout time.Duration // Deadline for a single frame write
+}
+
+// DefaultHeartbeat pings every 15s, so the connection carries a frame at
+// least that often even when the server's 10s ticks stop, and drops it
+// after three missed pings' worth of silence
+var DefaultHeartbeat = Heartbeat{
+	Interval:     15 * time.Second,
+	ReadTimeout:  45 * time.Second,
+	WriteTimeout: 10 * time.Second,
+}
+
+// Latency returns the most recent ping round-trip time (0 until measured)
+func (c *Client) Latency() time.Duration {
+	return time.Duration(c.latency.Load())
+}
+
+// LastSeen returns when the last frame arrived from the server
+func (c *Client) LastSeen() time.Time {
+	ns := c.lastSeen.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// touch records an inbound frame and pushes the read deadline forward
+func (c *Client) touch(conn *websocket.Conn) {
+	now := time.Now()
+	c.lastSeen.Store(now.UnixNano())
+	if c.Heartbeat.ReadTimeout > 0 {
+		conn.SetReadDeadline(now.Add(c.Heartbeat.ReadTimeout))
+	}
+}
+
+// recordPong completes a round-trip measurement
+func (c *Client) recordPong() {
+	sent := c.pingSent.Swap(0)
+	if sent == 0 {
+		return
+	}
+	c.latency.Store(time.Now().UnixNano() - sent)
+}
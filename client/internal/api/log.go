@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// maxLoggedFrame bounds how much of a frame a debug record carries
+const maxLoggedFrame = 512
+
+// log returns the client's logger, or one that discards everything
+func (c *Client) log() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return c.Logger
+}
+
+// logFrame records a frame at debug level, without the session token
+func (c *Client) logFrame(msg string, data []byte) {
+	l := c.log()
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	var head struct {
+		Type      string `json:"type"`
+		RequestId string `json:"requestId"`
+	}
+	json.Unmarshal(data, &head)
+	if head.Type == "ping" || head.Type == "pong" {
+		return
+	}
+	args := []any{"type", head.Type, "bytes", len(data)}
+	if head.RequestId != "" {
+		args = append(args, "request", head.RequestId)
+	}
+	l.Debug(msg, append(args, "frame", truncate(string(redactTokens(data))))...)
+}
+
+// truncate shortens s to maxLoggedFrame bytes
+func truncate(s string) string {
+	if len(s) <= maxLoggedFrame {
+		return s
+	}
+	return s[:maxLoggedFrame] + "…"
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PricePoint is one trade in the market's price history
+type PricePoint struct {
+	Timestamp int64   `json:"timestamp"` // Unix milliseconds
+	Price     float64 `json:"price"`
+}
+
+// MarketSnapshot is the order book as served by GET /market
+type MarketSnapshot struct {
+	Bids         []MarketOrder `json:"bids"`
+	Asks         []MarketOrder `json:"asks"`
+	PriceHistory []PricePoint  `json:"priceHistory"` // Oldest first, last 1000 trades
+	LastPrice    float64       `json:"lastPrice"`
+}
+
+// FetchMarket loads the current order book over HTTP; the server does not
+// push market_update on its own, so clients poll this after trading
+func FetchMarket(ctx context.Context, wsURL string) (MarketSnapshot, error) {
+	var snap MarketSnapshot
+	err := getJSON(ctx, wsURL, "/market", &snap)
+	return snap, err
+}
+
+// getJSON fetches path from the server's HTTP origin and decodes the body
+func getJSON(ctx context.Context, wsURL, path string, v any) error {
+	base, err := HTTPBaseURL(wsURL)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import "errors"
+
+// Stats counts what happened to inbound frames so losses are never silent
+type Stats struct {
+	Received       uint64 // Frames read off the socket, excluding pongs
+	Delivered      uint64 // Messages handed to the Messages channel
+	Dropped        uint64 // Messages discarded because the client was closed first
+	ParseErrors    uint64 // Frames that could not be decoded
+	UnknownTypes   uint64 // Subset of ParseErrors with an unrecognized type
+	LastParseError string // Most recent decode failure
+	Queued         int    // Messages waiting for the consumer right now
+	MaxQueued      int    // High-water mark of Queued
+}
+
+// Stats returns a snapshot of the inbound delivery counters
+func (c *Client) Stats() Stats {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	s := c.stats
+	s.Queued = len(c.queue)
+	return s
+}
+
+// enqueue buffers ev for delivery; the queue is unbounded so a slow
+// consumer never causes the read pump to discard anything
+func (c *Client) enqueue(ev Event) {
+	c.queueMu.Lock()
+	c.queue = append(c.queue, ev)
+	if len(c.queue) > c.stats.MaxQueued {
+		c.stats.MaxQueued = len(c.queue)
+	}
+	c.queueMu.Unlock()
+
+	select {
+	case c.queueReady <- struct{}{}:
+	default:
+	}
+}
+
+// recordReceived counts a decoded frame, including acks and errors that
+// go straight to a waiting Request
+func (c *Client) recordReceived() {
+	c.queueMu.Lock()
+	c.stats.Received++
+	c.queueMu.Unlock()
+}
+
+// recordParseError counts a frame that could not be decoded
+func (c *Client) recordParseError(err error) {
+	c.queueMu.Lock()
+	c.stats.Received++
+	c.stats.ParseErrors++
+	if errors.Is(err, ErrUnknownType) {
+		c.stats.UnknownTypes++
+	}
+	c.stats.LastParseError = err.Error()
+	c.queueMu.Unlock()
+}
+
+// dequeue pops the oldest buffered message
+func (c *Client) dequeue() (Event, bool) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	if len(c.queue) == 0 {
+		return nil, false
+	}
+	ev := c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+	return ev, true
+}
+
+// deliverPump moves queued messages onto Messages in arrival order,
+// blocking on the consumer instead of dropping
+func (c *Client) deliverPump() {
+	for {
+		ev, ok := c.dequeue()
+		if !ok {
+			select {
+			case <-c.queueReady:
+				continue
+			case <-c.Done:
+				return
+			}
+		}
+
+		select {
+		case c.Messages <- ev:
+			c.queueMu.Lock()
+			c.stats.Delivered++
+			c.queueMu.Unlock()
+		case <-c.Done:
+			c.queueMu.Lock()
+			dropped := 1 + len(c.queue)
+			c.stats.Dropped += uint64(dropped)
+			c.queue = nil
+			c.queueMu.Unlock()
+			c.log().Warn("dropped undelivered messages", "count", dropped)
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recorded frame directions
+const (
+	DirIn     = "in"     // A frame from the server, pongs included
+	DirOut    = "out"    // A frame we sent, pings included
+	DirMarket = "market" // A GET /market response, recorded by the caller
+)
+
+// Frame is one line of a recording
+type Frame struct {
+	Time time.Time       `json:"t"`
+	Dir  string          `json:"dir"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Raw  string          `json:"raw,omitempty"` // A frame that isn't valid JSON, as is
+}
+
+// Bytes returns the frame as it went over the wire
+func (f Frame) Bytes() []byte {
+	if f.Raw != "" {
+		return []byte(f.Raw)
+	}
+	return f.Data
+}
+
+// Recorder appends frames to a JSONL recording. Session tokens, in auth
+// messages and claim acks, are redacted. It is safe for concurrent use; the first
+// write error stops recording and is kept for Err.
+type Recorder struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	err    error
+}
+
+// NewRecorder records to w
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{w: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	return r
+}
+
+// CreateRecording starts a recording at path, replacing any file there.
+// Recordings hold game state, so only the owner may read them.
+func CreateRecording(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f), nil
+}
+
+// Record appends one frame stamped with the current time
+func (r *Recorder) Record(dir string, data []byte) {
+	f := Frame{Time: time.Now(), Dir: dir}
+	if json.Valid(data) {
+		f.Data = redactTokens(data)
+	} else {
+		f.Raw = string(data)
+	}
+
+	line, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.w.Write(append(line, '\n'))
+	// Flush every frame so a crash leaves a usable recording
+	r.err = r.w.Flush()
+}
+
+// RecordMarket appends a market snapshot the caller fetched
+func (r *Recorder) RecordMarket(snap MarketSnapshot) {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+	r.Record(DirMarket, data)
+}
+
+// Err returns the write error that stopped recording, if any
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close flushes the recording and closes the underlying writer if it has
+// a Close method
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.w.Flush()
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// redactTokens blanks the session token in an auth message and the
+// tokens in a claim ack's session
+func redactTokens(data []byte) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return data
+	}
+	redacted := json.RawMessage(`"redacted"`)
+	changed := false
+	if _, ok := fields["token"]; ok {
+		fields["token"] = redacted
+		changed = true
+	}
+	if raw, ok := fields["session"]; ok {
+		var session map[string]json.RawMessage
+		if json.Unmarshal(raw, &session) == nil && session != nil {
+			session["token"] = redacted
+			session["refreshToken"] = redacted
+			fields["session"], _ = json.Marshal(session)
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// ReadRecording parses a recording, oldest frame first
+func ReadRecording(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Snapshots can be large
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var f Frame
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		frames = append(frames, f)
+	}
+	return frames, scanner.Err()
+}
+
+// LoadRecording reads the recording at path
+func LoadRecording(path string) ([]Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
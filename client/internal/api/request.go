@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultRequestTimeout bounds awaitable requests whose context has no deadline
+const DefaultRequestTimeout = 10 * time.Second
+
+// ServerError is a server-side rejection of one of our requests
+type ServerError struct {
+	RequestId string
+	Message   string
+	Code      string // Set for auth failures
+}
+
+func (e *ServerError) Error() string {
+	return e.Message
+}
+
+// nextRequestId returns a fresh id for correlating a request
+func (c *Client) nextRequestId() string {
+	return "r" + strconv.FormatUint(c.requestSeq.Add(1), 10)
+}
+
+// Request sends msg and waits for the server's matching ack or error.
+// A *ServerError is returned when the server rejects the request.
+func (c *Client) Request(ctx context.Context, msg ClientMessage) (AckEvent, error) {
+	if _, ok := ctx.Deadline(); !ok && c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+
+	if msg.RequestId == "" {
+		msg.RequestId = c.nextRequestId()
+	}
+
+	reply := make(chan Event, 1)
+	c.pendingMu.Lock()
+	c.pending[msg.RequestId] = reply
+	c.pendingMu.Unlock()
+
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, msg.RequestId)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.Send(msg); err != nil {
+		return AckEvent{}, err
+	}
+
+	select {
+	case ev := <-reply:
+		switch ev := ev.(type) {
+		case AckEvent:
+			return ev, nil
+		case ErrorEvent:
+			return AckEvent{}, &ServerError{RequestId: ev.RequestId, Message: ev.Message, Code: ev.Code}
+		default:
+			return AckEvent{}, fmt.Errorf("unexpected %s reply", ev.Type())
+		}
+	case <-ctx.Done():
+		c.log().Warn("no reply to request", "type", msg.Type, "request", msg.RequestId, "err", ctx.Err())
+		return AckEvent{}, fmt.Errorf("%s: %w", msg.Type, ctx.Err())
+	case <-c.Done:
+		return AckEvent{}, ErrClosed
+	}
+}
+
+// resolve hands an ack or error to the Request waiting for it, reporting
+// whether ev was consumed
+func (c *Client) resolve(ev Event) bool {
+	var id string
+	switch ev := ev.(type) {
+	case AckEvent:
+		id = ev.RequestId
+	case ErrorEvent:
+		id = ev.RequestId
+	}
+	if id == "" {
+		return false
+	}
+
+	c.pendingMu.Lock()
+	reply, ok := c.pending[id]
+	c.pendingMu.Unlock()
+
+	if !ok {
+		return false
+	}
+	reply <- ev
+	return true
+}
+
+// RequestRouteContext requests a route and waits for the server to accept
+// the request; the ack carries the new route's id
+func (c *Client) RequestRouteContext(ctx context.Context, to string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type: "request_route",
+		To:   to,
+	})
+}
+
+// AcceptRouteContext accepts a pending route request and waits for the result
+func (c *Client) AcceptRouteContext(ctx context.Context, routeId string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:    "accept_route",
+		RouteId: routeId,
+	})
+}
+
+// RejectRouteContext rejects a pending route request and waits for the result
+func (c *Client) RejectRouteContext(ctx context.Context, routeId string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:    "reject_route",
+		RouteId: routeId,
+	})
+}
+
+// WithdrawRouteContext takes back a route request we sent and waits for
+// the result
+func (c *Client) WithdrawRouteContext(ctx context.Context, routeId string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:    "withdraw_route",
+		RouteId: routeId,
+	})
+}
+
+// PlaceOrderContext places a market order and waits for the result; the
+// ack carries the order's id
+func (c *Client) PlaceOrderContext(ctx context.Context, side string, price float64, amount int) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:   "place_order",
+		Side:   side,
+		Price:  price,
+		Amount: amount,
+	})
+}
+
+// CancelOrderContext cancels a market order and waits for the result
+func (c *Client) CancelOrderContext(ctx context.Context, orderId string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:    "cancel_order",
+		OrderId: orderId,
+	})
+}
+
+// InvestPoiContext invests nits in a POI and waits for the result
+func (c *Client) InvestPoiContext(ctx context.Context, poiId string, amount int) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:   "invest_poi",
+		PoiId:  poiId,
+		Amount: amount,
+	})
+}
+
+// UpgradeRouteContext upgrades a route's capacity and waits for the result
+func (c *Client) UpgradeRouteContext(ctx context.Context, routeId string) (AckEvent, error) {
+	return c.Request(ctx, ClientMessage{
+		Type:    "upgrade_route",
+		RouteId: routeId,
+	})
+}
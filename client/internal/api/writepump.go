@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrNotConnected is returned by Send while there is no live connection
+	ErrNotConnected = errors.New("not connected")
+	// ErrClosed is returned by Send after Close
+	ErrClosed = errors.New("client closed")
+)
+
+// outboundFrame is one queued write, the connection it was meant for and
+// where to report its outcome
+type outboundFrame struct {
+	data   []byte
+	conn   *websocket.Conn
+	result chan error
+}
+
+// Send queues a message for the write pump and waits until it has been
+// written, returning the write error if any. It is safe for concurrent use.
+// While there is no live connection, including while reconnecting, it
+// fails fast with ErrNotConnected; a frame is never carried over to the
+// next connection. Messages without a RequestId get one; the server's ack
+// or error for a fire-and-forget send is delivered on Messages.
+func (c *Client) Send(msg ClientMessage) error {
+	if msg.RequestId == "" {
+		msg.RequestId = c.nextRequestId()
+	}
+
+	c.mu.Lock()
+	conn, stop := c.conn, c.stop
+	c.mu.Unlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	frame := outboundFrame{data: data, conn: conn, result: make(chan error, 1)}
+	select {
+	case c.outbound <- frame:
+	case <-stop:
+		return ErrNotConnected
+	case <-c.Done:
+		return ErrClosed
+	}
+
+	select {
+	case err := <-frame.result:
+		return err
+	case <-stop:
+		// The connection's writer is gone; report a write that made it
+		select {
+		case err := <-frame.result:
+			return err
+		default:
+			return ErrNotConnected
+		}
+	case <-c.Done:
+		return ErrClosed
+	}
+}
+
+// writePump is the sole writer on conn: it drains the outbound queue and
+// sends heartbeat pings until the connection fails or stop is closed
+func (c *Client) writePump(conn *websocket.Conn, stop <-chan struct{}) {
+	var ping <-chan time.Time
+	if c.Heartbeat.Interval > 0 {
+		ticker := time.NewTicker(c.Heartbeat.Interval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			c.failQueued(ErrNotConnected)
+			return
+		case <-c.Done:
+			c.failQueued(ErrClosed)
+			return
+		case frame := <-c.outbound:
+			if frame.conn != conn {
+				// Queued for a connection that has since dropped
+				frame.result <- ErrNotConnected
+				continue
+			}
+			err := c.writeFrame(conn, frame.data)
+			frame.result <- err
+			if err != nil {
+				// Unblock readPump so it can reconnect
+				conn.Close()
+				c.failQueued(ErrNotConnected)
+				return
+			}
+		case <-ping:
+			c.pingSent.Store(time.Now().UnixNano())
+			if err := c.writeMessage(conn, ClientMessage{Type: "ping"}); err != nil {
+				conn.Close()
+				c.failQueued(ErrNotConnected)
+				return
+			}
+		}
+	}
+}
+
+// failQueued rejects every frame still waiting in the outbound queue
+func (c *Client) failQueued(err error) {
+	for {
+		select {
+		case frame := <-c.outbound:
+			frame.result <- err
+		default:
+			return
+		}
+	}
+}
+
+// writeMessage serializes msg and writes it directly on conn; only the
+// write pump (or dial, before the pump starts) may call it
+func (c *Client) writeMessage(conn *websocket.Conn, msg ClientMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(conn, data)
+}
+
+// writeFrame writes one text frame under the write deadline
+func (c *Client) writeFrame(conn *websocket.Conn, data []byte) error {
+	if c.Heartbeat.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(c.Heartbeat.WriteTimeout))
+	}
+	err := conn.WriteMessage(websocket.TextMessage, data)
+	if err != nil {
+		c.log().Warn("write failed", "err", err)
+		return err
+	}
+	if c.Recorder != nil {
+		c.Recorder.Record(DirOut, data)
+	}
+	c.logFrame("sent", data)
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package bot runs Go strategies against the real foam protocol. A Bot keeps
+// a World model current from server events, calls the Strategy's callbacks
+// and offers action helpers that wait for the server's answer.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/market"
+	"github.com/philip/foam/internal/rules"
+)
+
+// Strategy decides what a bot does. Callbacks run one at a time on the
+// bot's goroutine, after the World has absorbed the event, so they may
+// block on actions.
+type Strategy interface {
+	// OnStart runs once the first snapshot (state, routes, POIs) is in
+	OnStart(b *Bot)
+	OnTick(b *Bot, ev api.TickEvent)
+	OnPoiUpdate(b *Bot, poi api.IntersectionState)
+	OnContest(b *Bot, ev api.PoiContestEvent)
+	OnFill(b *Bot, ev api.OrderFilledEvent)
+	OnRouteRequest(b *Bot, ev api.RouteRequestEvent)
+}
+
+// NopStrategy ignores every callback; embed it to handle only a subset
+type NopStrategy struct{}
+
+func (NopStrategy) OnStart(*Bot)                               {}
+func (NopStrategy) OnTick(*Bot, api.TickEvent)                 {}
+func (NopStrategy) OnPoiUpdate(*Bot, api.IntersectionState)    {}
+func (NopStrategy) OnContest(*Bot, api.PoiContestEvent)        {}
+func (NopStrategy) OnFill(*Bot, api.OrderFilledEvent)          {}
+func (NopStrategy) OnRouteRequest(*Bot, api.RouteRequestEvent) {}
+
+// strategies holds the registered strategy constructors by name
+var strategies = map[string]func() Strategy{}
+
+// Register makes a strategy available to Lookup; call it from init
+func Register(name string, fn func() Strategy) {
+	if _, dup := strategies[name]; dup {
+		panic("bot: strategy registered twice: " + name)
+	}
+	strategies[name] = fn
+}
+
+// Lookup returns a fresh instance of the named strategy
+func Lookup(name string) (Strategy, error) {
+	fn, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q (expected one of %v)", name, Names())
+	}
+	return fn(), nil
+}
+
+// Names lists the registered strategies
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bot connects a Strategy to a Client
+type Bot struct {
+	Client   *api.Client
+	World    *World
+	Rules    rules.Params
+	Logger   *slog.Logger  // Action log; nil discards
+	Timeout  time.Duration // Bounds each action
+	strategy Strategy
+	ctx      context.Context
+	started  bool
+}
+
+// New returns a Bot for an already connected client
+func New(client *api.Client, s Strategy) *Bot {
+	return &Bot{
+		Client:   client,
+		World:    NewWorld(),
+		Rules:    rules.Current,
+		Logger:   slog.Default(),
+		Timeout:  api.DefaultRequestTimeout,
+		strategy: s,
+		ctx:      context.Background(),
+	}
+}
+
+// log returns the bot's logger, discarding when none is set
+func (b *Bot) log() *slog.Logger {
+	if b.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return b.Logger
+}
+
+// Apply feeds an event to the World and the Strategy. Run calls it for
+// every server event; call it directly to seed the World or in tests.
+func (b *Bot) Apply(ev api.Event) {
+	b.World.Apply(ev)
+
+	s := b.strategy
+	switch ev := ev.(type) {
+	case api.PoisEvent:
+		// The end of the snapshot, first on connect and again on reconnect
+		if !b.started {
+			b.started = true
+			s.OnStart(b)
+		}
+	case api.TickEvent:
+		s.OnTick(b, ev)
+	case api.PoiUpdateEvent:
+		s.OnPoiUpdate(b, ev.Poi)
+	case api.IntersectionCreatedEvent:
+		s.OnPoiUpdate(b, ev.Intersection)
+	case api.PoiContestEvent:
+		s.OnContest(b, ev)
+	case api.OrderFilledEvent:
+		s.OnFill(b, ev)
+	case api.RouteRequestEvent:
+		s.OnRouteRequest(b, ev)
+	case api.ErrorEvent:
+		b.log().Warn("server error", "message", ev.Message)
+	}
+}
+
+// Run processes events until ctx is done or the client gives up
+func (b *Bot) Run(ctx context.Context) error {
+	b.ctx = ctx
+	for {
+		select {
+		case ev := <-b.Client.Messages:
+			b.Apply(ev)
+		case ev := <-b.Client.Reconnects:
+			b.log().Warn("connection lost, reconnecting", "attempt", ev.Attempt, "err", ev.Err)
+		case err := <-b.Client.Errors:
+			return err
+		case <-b.Client.Done:
+			return api.ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// actionContext bounds one action by the bot's Timeout
+func (b *Bot) actionContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(b.ctx, b.Timeout)
+}
+
+// Invest puts nits into a POI
+func (b *Bot) Invest(poiId string, amount int) error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	if _, err := b.Client.InvestPoiContext(ctx, poiId, amount); err != nil {
+		return fmt.Errorf("invest %d in %s: %w", amount, poiId, err)
+	}
+	b.log().Info("invested", "poi", poiId, "amount", amount)
+	return nil
+}
+
+// PlaceOrder places a limit order and returns its id
+func (b *Bot) PlaceOrder(side string, price float64, amount int) (string, error) {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	ack, err := b.Client.PlaceOrderContext(ctx, side, price, amount)
+	if err != nil {
+		return "", fmt.Errorf("place %s %d @ %.2f: %w", side, amount, price, err)
+	}
+	b.log().Info("placed order", "order", ack.OrderId, "side", side, "amount", amount, "price", price)
+	return ack.OrderId, nil
+}
+
+// CancelOrder cancels one of our orders
+func (b *Bot) CancelOrder(orderId string) error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	if _, err := b.Client.CancelOrderContext(ctx, orderId); err != nil {
+		return fmt.Errorf("cancel %s: %w", orderId, err)
+	}
+	b.log().Info("cancelled order", "order", orderId)
+	return nil
+}
+
+// RequestRoute asks another player for a route and returns its id. The
+// request stays in the World's Outbound until it's answered.
+func (b *Bot) RequestRoute(to string) (string, error) {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	ack, err := b.Client.RequestRouteContext(ctx, to)
+	if err != nil {
+		return "", fmt.Errorf("request route to %s: %w", to, err)
+	}
+	b.World.Outbound = append(b.World.Outbound, api.OutboundRequest{To: to, RouteId: ack.RouteId})
+	b.log().Info("requested route", "to", to, "route", ack.RouteId)
+	return ack.RouteId, nil
+}
+
+// AcceptRoute accepts an inbound route request
+func (b *Bot) AcceptRoute(routeId string) error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	if _, err := b.Client.AcceptRouteContext(ctx, routeId); err != nil {
+		return fmt.Errorf("accept route %s: %w", routeId, err)
+	}
+	b.log().Info("accepted route", "route", routeId)
+	return nil
+}
+
+// RejectRoute declines an inbound route request
+func (b *Bot) RejectRoute(routeId string) error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	if _, err := b.Client.RejectRouteContext(ctx, routeId); err != nil {
+		return fmt.Errorf("reject route %s: %w", routeId, err)
+	}
+	b.log().Info("rejected route", "route", routeId)
+	return nil
+}
+
+// UpgradeRoute buys more capacity on one of our routes
+func (b *Bot) UpgradeRoute(routeId string) error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	if _, err := b.Client.UpgradeRouteContext(ctx, routeId); err != nil {
+		return fmt.Errorf("upgrade route %s: %w", routeId, err)
+	}
+	b.log().Info("upgraded route", "route", routeId)
+	return nil
+}
+
+// RefreshMarket loads the order book into the World; the server doesn't
+// push it
+func (b *Bot) RefreshMarket() error {
+	ctx, cancel := b.actionContext()
+	defer cancel()
+	snap, err := api.FetchMarket(ctx, b.Client.URL)
+	if err != nil {
+		return err
+	}
+	b.World.Bids, b.World.Asks = snap.Bids, snap.Asks
+	return nil
+}
+
+// Mid is the market's midpoint price, if both sides have orders
+func (b *Bot) Mid() (float64, bool) {
+	return market.Mid(b.World.Bids, b.World.Asks)
+}
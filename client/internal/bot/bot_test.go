@@ -0,0 +1,133 @@
+package bot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/bot"
+	"github.com/philip/foam/internal/fakeserver"
+)
+
+const waitTimeout = 5 * time.Second
+
+// crossing places four players so that al-bob and cy-dee cross
+func crossing(srv *fakeserver.Server) {
+	for _, p := range []api.PlayerState{
+		{Username: "al", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 0, Lng: 0}},
+		{Username: "bob", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 1, Lng: 1}},
+		{Username: "cy", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 0, Lng: 1}},
+		{Username: "dee", Nits: 100, ProductionRate: 1, Coordinates: api.Coordinates{Lat: 1, Lng: 0}},
+	} {
+		srv.AddPlayer(p)
+	}
+	srv.AddRoute("al", "bob")
+	srv.AddRoute("cy", "dee")
+}
+
+// connect returns a client for username that has consumed its snapshot
+func connect(t *testing.T, srv *fakeserver.Server, username string) *api.Client {
+	t.Helper()
+	c := api.NewClient(srv.URL, username)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("connect %s: %v", username, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	for ev := range c.Messages {
+		if _, ok := ev.(api.PoisEvent); ok {
+			break
+		}
+	}
+	return c
+}
+
+// run connects username and runs s on it until the test ends
+func run(t *testing.T, srv *fakeserver.Server, username string, s bot.Strategy) {
+	t.Helper()
+	c := api.NewClient(srv.URL, username)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("connect %s: %v", username, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.New(c, s).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		c.Close()
+	})
+}
+
+// waitFor polls cond until it holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s within %v", what, waitTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTerritorialDefends(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	crossing(srv)
+	poi := srv.Pois()[0].Id
+
+	run(t, srv, "al", &bot.Territorial{MaxHeat: 100})
+	cy := connect(t, srv, "cy")
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	// The bot reads its own connection's events, so al invests over another
+	al := connect(t, srv, "al")
+	if _, err := al.InvestPoiContext(ctx, poi, 10); err != nil {
+		t.Fatalf("al invest: %v", err)
+	}
+	if _, err := cy.InvestPoiContext(ctx, poi, 11); err != nil {
+		t.Fatalf("cy invest: %v", err)
+	}
+
+	// Outbidding cy's 11 takes 2 more on top of al's 10
+	waitFor(t, "defence", func() bool {
+		p, _ := srv.Poi(poi)
+		return p.Controller == "al" && p.Investments["al"] == 12
+	})
+}
+
+func TestExpansionistRequestsRoutes(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	crossing(srv)
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	// cy is on the order book, so al knows of it
+	cy := connect(t, srv, "cy")
+	if _, err := cy.PlaceOrderContext(ctx, "ask", 2, 10); err != nil {
+		t.Fatalf("cy order: %v", err)
+	}
+
+	run(t, srv, "al", &bot.Expansionist{Reserve: 100, MaxHeat: 100})
+
+	timeout := time.After(waitTimeout)
+	for {
+		select {
+		case ev := <-cy.Messages:
+			if req, ok := ev.(api.RouteRequestEvent); ok {
+				if req.From != "al" {
+					t.Errorf("route request from %s, want al", req.From)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("cy got no route request within %v", waitTimeout)
+		}
+	}
+}
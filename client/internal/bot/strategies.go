@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// Built-in strategies, after the server's BotDO behaviors
+func init() {
+	Register("passive", func() Strategy { return &Passive{} })
+	Register("territorial", func() Strategy { return &Territorial{Reserve: 20, MaxHeat: 50, Every: 6} })
+	Register("trader", func() Strategy { return &Trader{Spread: 0.05, Size: 10, Every: 6} })
+	Register("expansionist", func() Strategy { return &Expansionist{Reserve: 20, MaxHeat: 50, Every: 3} })
+}
+
+// Passive accepts every route request and otherwise just produces
+type Passive struct {
+	NopStrategy
+}
+
+func (p *Passive) OnRouteRequest(b *Bot, ev api.RouteRequestEvent) {
+	if err := b.AcceptRoute(ev.RouteId); err != nil {
+		b.log().Warn("accept route failed", "err", err)
+	}
+}
+
+// Territorial defends the POIs it controls and, while its heat allows,
+// takes over POIs it can afford
+type Territorial struct {
+	Passive
+	Reserve int // Nits never spent
+	MaxHeat int // Don't start attacks above this heat
+	Every   int // Look for a POI to take every this many ticks
+	ticks   int
+}
+
+// OnContest outbids an attacker on a POI we controlled. The server sends
+// poi_contest before the poi_update, so the attack isn't in the World yet.
+func (t *Territorial) OnContest(b *Bot, ev api.PoiContestEvent) {
+	poi, ok := b.World.Pois[ev.PoiId]
+	if !ok || ev.Attacker == b.World.Me() {
+		return
+	}
+	need := poi.Investments[ev.Attacker] + ev.Amount - poi.Investments[b.World.Me()] + 1
+	if need <= 0 {
+		return
+	}
+	if need > b.World.Player.Nits-t.Reserve {
+		b.log().Warn("can't afford to defend POI", "poi", poi.Id, "need", need)
+		return
+	}
+	if err := b.Invest(poi.Id, need); err != nil {
+		b.log().Warn("defend POI failed", "err", err)
+	}
+}
+
+// OnTick periodically takes over the cheapest POI we can afford
+func (t *Territorial) OnTick(b *Bot, ev api.TickEvent) {
+	t.ticks++
+	if t.Every > 0 && t.ticks%t.Every != 0 {
+		return
+	}
+	w := b.World
+	if w.Player.Heat+b.Rules.InvestHeat(true) > t.MaxHeat {
+		return
+	}
+
+	budget := w.Player.Nits - t.Reserve
+	var target api.IntersectionState
+	cost := 0
+	for _, poi := range w.Contestable() {
+		top := 0
+		for player, invested := range poi.Investments {
+			if player != w.Me() {
+				top = max(top, invested)
+			}
+		}
+		need := top - poi.Investments[w.Me()] + 1
+		if need <= budget && (cost == 0 || need < cost) {
+			target, cost = poi, need
+		}
+	}
+	if cost == 0 {
+		return
+	}
+	if err := b.Invest(target.Id, cost); err != nil {
+		b.log().Warn("take POI failed", "err", err)
+	}
+}
+
+// Trader quotes both sides of the market around the midpoint, replacing
+// its quotes periodically
+type Trader struct {
+	Passive
+	Spread float64 // Distance from the mid to each quote, as a fraction
+	Size   int     // Nits per quote
+	Every  int     // Requote every this many ticks
+	ticks  int
+	quotes map[string]int // Our resting quotes' remaining nits, by order id
+}
+
+func (t *Trader) OnStart(b *Bot) {
+	t.requote(b)
+}
+
+func (t *Trader) OnTick(b *Bot, ev api.TickEvent) {
+	t.ticks++
+	if t.Every > 0 && t.ticks%t.Every == 0 {
+		t.requote(b)
+	}
+}
+
+func (t *Trader) OnFill(b *Bot, ev api.OrderFilledEvent) {
+	b.log().Info("filled", "order", ev.OrderId, "side", ev.Side, "amount", ev.Amount, "price", ev.Price)
+	if left, ok := t.quotes[ev.OrderId]; ok {
+		// A fully filled quote is off the book; there's nothing to cancel
+		if left -= ev.Amount; left > 0 {
+			t.quotes[ev.OrderId] = left
+		} else {
+			delete(t.quotes, ev.OrderId)
+		}
+	}
+}
+
+// requote cancels our quotes and places fresh ones. If a cancel fails the
+// old quote may still be resting, so nothing is placed until a later
+// requote gets them all off the book.
+func (t *Trader) requote(b *Bot) {
+	cancelled := true
+	for id := range t.quotes {
+		err := b.CancelOrder(id)
+		if err == nil {
+			delete(t.quotes, id)
+			continue
+		}
+		b.log().Warn("cancel quote failed; not requoting", "err", err)
+		cancelled = false
+		// The server doesn't have it, perhaps filled while we were away
+		var rejected *api.ServerError
+		if errors.As(err, &rejected) {
+			delete(t.quotes, id)
+		}
+	}
+	if !cancelled {
+		return
+	}
+	if t.quotes == nil {
+		t.quotes = make(map[string]int)
+	}
+
+	if err := b.RefreshMarket(); err != nil {
+		b.log().Warn("refresh market failed", "err", err)
+		return
+	}
+	mid, ok := b.Mid()
+	if !ok {
+		mid = 1
+	}
+
+	bid, ask := mid*(1-t.Spread), mid*(1+t.Spread)
+	if float64(b.World.Player.Nits) >= bid*float64(t.Size) {
+		if id, err := b.PlaceOrder("bid", bid, t.Size); err == nil {
+			t.quotes[id] = t.Size
+		} else {
+			b.log().Warn("quote failed", "err", err)
+		}
+	}
+	if b.World.Player.Nits >= t.Size {
+		if id, err := b.PlaceOrder("ask", ask, t.Size); err == nil {
+			t.quotes[id] = t.Size
+		} else {
+			b.log().Warn("quote failed", "err", err)
+		}
+	}
+}
+
+// Expansionist grows its network: it asks a player it knows of for a
+// route every few ticks and, with nits to spare, upgrades its smallest
+// route
+type Expansionist struct {
+	Passive
+	Reserve int // Nits never spent
+	MaxHeat int // Don't upgrade above this heat
+	Every   int // Expand every this many ticks
+	ticks   int
+	asked   map[string]bool // Players already asked once; cleared when everyone has been
+}
+
+func (e *Expansionist) OnStart(b *Bot) {
+	e.expand(b)
+}
+
+func (e *Expansionist) OnTick(b *Bot, ev api.TickEvent) {
+	e.ticks++
+	if e.Every > 0 && e.ticks%e.Every == 0 {
+		e.expand(b)
+	}
+}
+
+// expand requests one new route and upgrades one existing route
+func (e *Expansionist) expand(b *Bot) {
+	if err := b.RefreshMarket(); err != nil {
+		// The book is only one source of players; carry on without it
+		b.log().Warn("refresh market failed", "err", err)
+	}
+	if to, ok := e.nextStranger(b.World.Strangers()); ok {
+		if _, err := b.RequestRoute(to); err != nil {
+			b.log().Warn("request route failed", "err", err)
+		}
+	}
+
+	w := b.World
+	if w.Player.Nits-b.Rules.UpgradeCost < e.Reserve || w.Player.Heat+b.Rules.HeatUpgrade > e.MaxHeat {
+		return
+	}
+	var smallest *api.RouteState
+	for i, r := range w.Routes {
+		if r.Status == "active" && (smallest == nil || r.Capacity < smallest.Capacity) {
+			smallest = &w.Routes[i]
+		}
+	}
+	if smallest == nil {
+		return
+	}
+	if err := b.UpgradeRoute(smallest.Id); err != nil {
+		b.log().Warn("upgrade route failed", "err", err)
+	}
+}
+
+// nextStranger picks the first stranger not asked yet, so a player who
+// rejects us isn't asked again until everyone else has been
+func (e *Expansionist) nextStranger(strangers []string) (string, bool) {
+	if len(strangers) == 0 {
+		return "", false
+	}
+	if e.asked == nil {
+		e.asked = make(map[string]bool)
+	}
+	for _, p := range strangers {
+		if !e.asked[p] {
+			e.asked[p] = true
+			return p, true
+		}
+	}
+	clear(e.asked)
+	e.asked[strangers[0]] = true
+	return strangers[0], true
+}
@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"sort"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// World is a bot's model of the game, kept current from server events
+type World struct {
+	Player   api.PlayerState
+	Routes   []api.RouteState
+	Pois     map[string]api.IntersectionState
+	Requests []api.RouteRequestEvent // Inbound route requests awaiting an answer
+	Outbound []api.OutboundRequest   // Route requests we sent, awaiting an answer
+	Visible  []api.VisiblePlayer
+	Bids     []api.MarketOrder
+	Asks     []api.MarketOrder
+	Tolls    int // Toll income since the bot started
+}
+
+// World handles every server event; the assertion keeps it exhaustive
+var _ api.Handler = (*World)(nil)
+
+// NewWorld returns an empty world
+func NewWorld() *World {
+	return &World{Pois: make(map[string]api.IntersectionState)}
+}
+
+// Apply updates the world from one event
+func (w *World) Apply(ev api.Event) {
+	ev.Dispatch(w)
+}
+
+// Me returns our username
+func (w *World) Me() string {
+	return w.Player.Username
+}
+
+// Controlled returns the POIs we control, by id
+func (w *World) Controlled() []api.IntersectionState {
+	var out []api.IntersectionState
+	for _, poi := range w.sortedPois() {
+		if poi.Controller == w.Me() {
+			out = append(out, poi)
+		}
+	}
+	return out
+}
+
+// Contestable returns POIs we don't control, by id
+func (w *World) Contestable() []api.IntersectionState {
+	var out []api.IntersectionState
+	for _, poi := range w.sortedPois() {
+		if poi.Controller != w.Me() {
+			out = append(out, poi)
+		}
+	}
+	return out
+}
+
+// Partners returns the players we have routes with
+func (w *World) Partners() []string {
+	var out []string
+	for _, r := range w.Routes {
+		if r.PlayerA == w.Me() {
+			out = append(out, r.PlayerB)
+		} else {
+			out = append(out, r.PlayerA)
+		}
+	}
+	return out
+}
+
+// Strangers returns the players we know of, by name, that we have no
+// route or pending request with: visible players, players on the order
+// book and investors in known POIs
+func (w *World) Strangers() []string {
+	known := make(map[string]bool)
+	for _, p := range w.Visible {
+		known[p.Username] = true
+	}
+	for _, o := range append(append([]api.MarketOrder{}, w.Bids...), w.Asks...) {
+		known[o.Player] = true
+	}
+	for _, poi := range w.Pois {
+		for player := range poi.Investments {
+			known[player] = true
+		}
+	}
+
+	delete(known, w.Me())
+	for _, p := range w.Partners() {
+		delete(known, p)
+	}
+	for _, req := range w.Requests {
+		delete(known, req.From)
+	}
+	for _, req := range w.Outbound {
+		delete(known, req.To)
+	}
+
+	out := make([]string, 0, len(known))
+	for p := range known {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (w *World) sortedPois() []api.IntersectionState {
+	out := make([]api.IntersectionState, 0, len(w.Pois))
+	for _, poi := range w.Pois {
+		out = append(out, poi)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
+
+func (w *World) OnConnected(api.ConnectedEvent)     {}
+func (w *World) OnError(api.ErrorEvent)             {}
+func (w *World) OnAck(api.AckEvent)                 {}
+func (w *World) OnOrderFilled(api.OrderFilledEvent) {}
+
+func (w *World) OnState(ev api.StateEvent) {
+	w.Player = ev.Player
+}
+
+func (w *World) OnTick(ev api.TickEvent) {
+	w.Player.Nits = ev.Nits
+	w.Player.Heat = ev.Heat
+}
+
+func (w *World) OnHeatUpdate(ev api.HeatUpdateEvent) {
+	w.Player.Heat = ev.Heat
+}
+
+func (w *World) OnRouteRequest(ev api.RouteRequestEvent) {
+	w.Requests = append(w.Requests, ev)
+}
+
+func (w *World) OnRouteAccepted(ev api.RouteAcceptedEvent) {
+	w.Routes = append(w.Routes, ev.Route)
+	w.dropRequest(ev.RouteId)
+}
+
+func (w *World) OnRouteRejected(ev api.RouteRejectedEvent) {
+	w.dropRequest(ev.RouteId)
+}
+
+func (w *World) OnRouteWithdrawn(ev api.RouteWithdrawnEvent) {
+	w.dropRequest(ev.RouteId)
+}
+
+// dropRequest forgets an answered route request, whichever way it went
+func (w *World) dropRequest(routeId string) {
+	for i, req := range w.Requests {
+		if req.RouteId == routeId {
+			w.Requests = append(w.Requests[:i], w.Requests[i+1:]...)
+			return
+		}
+	}
+	for i, req := range w.Outbound {
+		if req.RouteId == routeId {
+			w.Outbound = append(w.Outbound[:i], w.Outbound[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *World) OnRoutes(ev api.RoutesEvent) {
+	w.Routes = ev.Routes
+	// Older servers leave out the pending requests
+	if ev.Requests != nil {
+		w.Requests = ev.Requests
+	}
+	if ev.Outbound != nil {
+		w.Outbound = ev.Outbound
+	}
+}
+
+func (w *World) OnPois(ev api.PoisEvent) {
+	w.Pois = make(map[string]api.IntersectionState, len(ev.Pois))
+	for _, poi := range ev.Pois {
+		w.Pois[poi.Id] = poi
+	}
+}
+
+func (w *World) OnIntersectionCreated(ev api.IntersectionCreatedEvent) {
+	w.Pois[ev.Intersection.Id] = ev.Intersection
+}
+
+func (w *World) OnPoiUpdate(ev api.PoiUpdateEvent) {
+	w.Pois[ev.Poi.Id] = ev.Poi
+}
+
+func (w *World) OnPoiContest(ev api.PoiContestEvent) {
+	if poi, ok := w.Pois[ev.PoiId]; ok && ev.NewController != nil {
+		poi.Controller = *ev.NewController
+		w.Pois[ev.PoiId] = poi
+	}
+}
+
+func (w *World) OnTollReceived(ev api.TollReceivedEvent) {
+	w.Tolls += ev.Amount
+}
+
+func (w *World) OnMarketUpdate(ev api.MarketUpdateEvent) {
+	w.Bids = ev.Bids
+	w.Asks = ev.Asks
+}
+
+func (w *World) OnVisibilityUpdate(ev api.VisibilityUpdateEvent) {
+	w.Visible = ev.VisiblePlayers
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultServer is the local wrangler dev server
+const DefaultServer = "ws://localhost:8787/ws"
+
+// usernamePattern mirrors the server's check in index.ts and handleAuth
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,7}$`)
+
+// Profile is a named server in the config file
+type Profile struct {
+	Server string `toml:"server"`
+	User   string `toml:"user"` // Default username for this server
+}
+
+// AlertRule says how to announce one kind of game event
+type AlertRule struct {
+	On        string `toml:"on"`        // poi_attacked, control_lost, toll or route_request
+	Bell      bool   `toml:"bell"`      // Ring the terminal bell
+	Notify    string `toml:"notify"`    // Desktop notification: "osc9" or "osc777"
+	Command   string `toml:"command"`   // Shell hook, run with FOAM_ALERT_* in its environment
+	Threshold int    `toml:"threshold"` // Minimum nits for toll and poi_attacked
+}
+
+// Alerts configures notifications for events that need attention while
+// the terminal is in the background
+type Alerts struct {
+	QuietHours string      `toml:"quiet_hours"` // "HH:MM-HH:MM" local time; alerts only reach the log
+	Rules      []AlertRule `toml:"rule"`
+}
+
+// Config is the client configuration from config.toml
+type Config struct {
+	Server   string             `toml:"server"` // URL or profile name
+	User     string             `toml:"user"`
+	Theme    string             `toml:"theme"`
+	LogFile  string             `toml:"log_file"`
+	Rules    string             `toml:"rules"` // Economy parameter version; empty for the latest
+	Profiles map[string]Profile `toml:"profiles"`
+	Alerts   *Alerts            `toml:"alerts"` // nil uses alert.DefaultRules
+}
+
+// Dir returns the foam config directory ($XDG_CONFIG_HOME/foam)
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "foam"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "foam"), nil
+}
+
+// StateDir returns the foam state directory ($XDG_STATE_HOME/foam) for
+// histories that aren't configuration
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "foam"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate state dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "foam"), nil
+}
+
+// Path returns the default config file path
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// Load reads the config file at path; a missing file yields an empty Config
+func Load(path string) (Config, error) {
+	var cfg Config
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ApplyEnv overrides fields from FOAM_* environment variables
+func (c *Config) ApplyEnv() {
+	if v := os.Getenv("FOAM_SERVER"); v != "" {
+		c.Server = v
+	}
+	if v := os.Getenv("FOAM_USER"); v != "" {
+		c.User = v
+	}
+	if v := os.Getenv("FOAM_THEME"); v != "" {
+		c.Theme = v
+	}
+	if v := os.Getenv("FOAM_LOG_FILE"); v != "" {
+		c.LogFile = v
+	}
+}
+
+// ResolveServer turns Server (a URL or profile name) into a WebSocket URL.
+// When Server names a profile with a user and no user is set, the
+// profile's user is adopted.
+func (c *Config) ResolveServer() (string, error) {
+	server := c.Server
+	if server == "" {
+		return DefaultServer, nil
+	}
+
+	if p, ok := c.Profiles[server]; ok {
+		if p.Server == "" {
+			return "", fmt.Errorf("profile %q has no server", server)
+		}
+		if c.User == "" {
+			c.User = p.User
+		}
+		return p.Server, nil
+	}
+
+	if strings.HasPrefix(server, "ws://") || strings.HasPrefix(server, "wss://") {
+		return server, nil
+	}
+
+	return "", fmt.Errorf("unknown server profile %q (expected a profile name or ws:// URL)", server)
+}
+
+// ValidateUsername applies the server's 1-7 alphanumeric rule
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid username %q: must be 1-7 alphanumeric characters", username)
+	}
+	return nil
+}
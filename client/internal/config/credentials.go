@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// CredentialsPath returns the session store path; it is kept apart from
+// config.toml so the config can be shared without leaking tokens
+func CredentialsPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "credentials.json"), nil
+}
+
+// credentialsKey identifies a session by server and (case-insensitive) user,
+// matching how the server names player objects
+func credentialsKey(server, username string) string {
+	return server + "#" + strings.ToLower(username)
+}
+
+func readCredentials(path string) (map[string]api.Session, error) {
+	sessions := map[string]api.Session{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sessions, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return sessions, nil
+}
+
+// LoadSession returns the stored session for username on server
+func LoadSession(server, username string) (api.Session, bool, error) {
+	path, err := CredentialsPath()
+	if err != nil {
+		return api.Session{}, false, err
+	}
+	sessions, err := readCredentials(path)
+	if err != nil {
+		return api.Session{}, false, err
+	}
+	s, ok := sessions[credentialsKey(server, username)]
+	return s, ok, nil
+}
+
+// SaveSession stores s for server, readable only by the current user
+func SaveSession(server string, s api.Session) error {
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+	sessions, err := readCredentials(path)
+	if err != nil {
+		return err
+	}
+	sessions[credentialsKey(server, s.Username)] = s
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	// Write then rename so a crash never leaves a truncated store
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package eventlog records notable game events with a severity, in memory
+// and as a JSONL history on disk.
+package eventlog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/philip/foam/internal/jsonl"
+)
+
+// Severity ranks an entry; higher is more urgent
+type Severity int
+
+const (
+	Info Severity = iota
+	Warn
+	Alert // Needs attention: our POI attacked, control lost
+	Error
+)
+
+var severityNames = []string{"info", "warn", "alert", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity is the inverse of String
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (expected %s)", name, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Entry kinds
+const (
+	KindRoute      = "route"
+	KindPoi        = "poi"
+	KindToll       = "toll"
+	KindMarket     = "market"
+	KindVisibility = "visibility"
+	KindConnection = "connection"
+	KindAction     = "action" // Outcome of something we did
+)
+
+// Entry is one logged event
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Severity Severity  `json:"severity"`
+	Kind     string    `json:"kind"`
+	Message  string    `json:"message"`
+	Server   string    `json:"server"`
+	Username string    `json:"username"`
+}
+
+// Matches reports whether the entry passes a minimum severity and a
+// case-insensitive substring filter (empty matches everything)
+func (e Entry) Matches(min Severity, text string) bool {
+	if e.Severity < min {
+		return false
+	}
+	if text == "" {
+		return true
+	}
+	text = strings.ToLower(text)
+	return strings.Contains(strings.ToLower(e.Message), text) || strings.Contains(e.Kind, text)
+}
+
+// Append adds e to the JSONL history at path
+func Append(path string, e Entry) error {
+	return jsonl.Append(path, e)
+}
+
+// Load reads up to the last limit entries for username on server from the
+// history at path. Unreadable lines are skipped.
+func Load(path, server, username string, limit int) ([]Entry, error) {
+	var entries []Entry
+	err := jsonl.Read(path, func(e Entry) {
+		if e.Server != server || !strings.EqualFold(e.Username, username) {
+			return
+		}
+		entries = append(entries, e)
+		if limit > 0 && len(entries) > 2*limit {
+			entries = append(entries[:0], entries[len(entries)-limit:]...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
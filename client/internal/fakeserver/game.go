@@ -0,0 +1,565 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/geo"
+)
+
+// reply answers one client message: errors carry its request id, and a
+// request that sent no error is acked
+type reply struct {
+	s         *Server
+	c         *conn
+	requestId string
+	failed    bool
+	ack       api.AckEvent
+}
+
+func (r *reply) error(format string, args ...any) {
+	r.failed = true
+	r.s.send(r.c, api.ErrorEvent{Message: fmt.Sprintf(format, args...), RequestId: r.requestId})
+}
+
+// send writes ev to one connection
+func (s *Server) send(c *conn, ev api.Event) {
+	frame, err := encode(ev)
+	if err != nil {
+		panic(err)
+	}
+	c.write(frame)
+}
+
+// handleFrame decodes and plays one client message
+func (s *Server) handleFrame(c *conn, data []byte) {
+	var msg api.ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.mu.Lock()
+		s.send(c, api.ErrorEvent{Message: "Invalid message format"})
+		s.mu.Unlock()
+		return
+	}
+
+	if msg.Type != "ping" && s.Intercept != nil && s.Intercept(c.username, msg) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &reply{s: s, c: c, requestId: msg.RequestId}
+	p := s.player(c.username)
+
+	switch msg.Type {
+	case "ping":
+		s.send(c, api.PongEvent{})
+		return
+	case "auth":
+		s.auth(r, p, msg)
+		return
+	}
+
+	if !p.authorized(c) {
+		s.send(c, api.ErrorEvent{Message: "Not authenticated", Code: api.CodeAuthRequired, RequestId: msg.RequestId})
+		return
+	}
+	if msg.Type == "claim" {
+		s.claim(r, p)
+		return
+	}
+	if !c.authed {
+		r.error("Not authenticated")
+		return
+	}
+
+	switch msg.Type {
+	case "request_route":
+		s.requestRoute(r, p, msg.To)
+	case "accept_route":
+		s.acceptRoute(r, p, msg.RouteId)
+	case "reject_route":
+		s.answerRoute(r, p, msg.RouteId, false)
+	case "withdraw_route":
+		s.answerRoute(r, p, msg.RouteId, true)
+	case "place_order":
+		s.placeOrder(r, p, msg.Side, msg.Price, msg.Amount)
+	case "cancel_order":
+		s.cancelOrder(r, p, msg.OrderId)
+	case "invest_poi":
+		s.investPoi(r, p, msg.PoiId, msg.Amount)
+	case "upgrade_route":
+		s.upgradeRoute(r, p, msg.RouteId)
+	}
+
+	if r.requestId != "" && !r.failed {
+		r.ack.RequestId = r.requestId
+		s.send(c, r.ack)
+	}
+}
+
+// auth authenticates the connection and sends the snapshot: connected,
+// state, routes and finally pois
+func (s *Server) auth(r *reply, p *player, msg api.ClientMessage) {
+	if !validUsername.MatchString(msg.Username) {
+		s.send(r.c, api.ErrorEvent{Message: "Username must be 1-7 alphanumeric characters"})
+		return
+	}
+	if !r.c.tokenOK {
+		switch p.checkToken(msg.Token) {
+		case api.CodeAuthExpired:
+			s.send(r.c, api.ErrorEvent{Message: "Session expired", Code: api.CodeAuthExpired})
+			return
+		case api.CodeAuthRequired:
+			s.send(r.c, api.ErrorEvent{Message: "Username is claimed; a valid token is required", Code: api.CodeAuthRequired})
+			return
+		}
+		r.c.tokenOK = true
+	}
+	r.c.authed = true
+
+	s.send(r.c, api.ConnectedEvent{Username: p.state.Username})
+	s.send(r.c, api.StateEvent{Player: p.state})
+
+	routes := []api.RouteState{}
+	for _, id := range p.state.Routes {
+		if route, ok := s.routes[id]; ok {
+			routes = append(routes, *route)
+		}
+	}
+	requestIds := make([]string, 0, len(s.requests))
+	for id := range s.requests {
+		requestIds = append(requestIds, id)
+	}
+	sort.Strings(requestIds)
+	inbound := []api.RouteRequestEvent{}
+	outbound := []api.OutboundRequest{}
+	for _, id := range requestIds {
+		switch req := s.requests[id]; {
+		case req.to == p.state.Username:
+			inbound = append(inbound, api.RouteRequestEvent{From: req.from, RouteId: id})
+		case req.from == p.state.Username:
+			outbound = append(outbound, api.OutboundRequest{To: req.to, RouteId: id})
+		}
+	}
+	s.send(r.c, api.RoutesEvent{Routes: routes, Requests: inbound, Outbound: outbound})
+
+	ids := append([]string{}, p.known...)
+	for id := range p.state.PoiInvestments {
+		if !contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	pois := []api.IntersectionState{}
+	for _, id := range ids {
+		if poi, ok := s.pois[id]; ok {
+			pois = append(pois, *poi)
+		}
+	}
+	s.send(r.c, api.PoisEvent{Pois: pois})
+}
+
+// Tick runs one production tick for every player: production (with the
+// controlled-POI bonus) and heat decay, then a tick message
+func (s *Server) Tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.sortedPlayers() {
+		s.expireSessions(p)
+		produced := s.Rules.Production(p.state.ProductionRate, len(p.controlled)) + p.frac
+		whole := int(produced)
+		p.frac = produced - float64(whole)
+		p.state.Nits += whole
+		p.state.Heat = s.Rules.HeatAfter(p.state.Heat, -s.Rules.HeatDecay)
+		s.broadcast(p, api.TickEvent{Nits: p.state.Nits, Heat: p.state.Heat})
+	}
+}
+
+// expireSessions demotes p's connections once its session token has
+// expired, like the worker does on its production tick
+func (s *Server) expireSessions(p *player) {
+	if p.session == nil || time.Now().UnixMilli() <= p.session.ExpiresAt {
+		return
+	}
+	for c := range p.conns {
+		if c.tokenOK {
+			c.tokenOK = false
+			s.send(c, api.ErrorEvent{Message: "Session expired", Code: api.CodeAuthExpired})
+		}
+	}
+}
+
+// claim claims an unclaimed player over its own connection; the other
+// connections must authenticate with the new token
+func (s *Server) claim(r *reply, p *player) {
+	if p.session != nil {
+		r.error("Username already claimed")
+		return
+	}
+	p.session = s.newSession(p, s.nextId("refresh"))
+	for c := range p.conns {
+		if c != r.c {
+			c.tokenOK = false
+			s.send(c, api.ErrorEvent{Message: "Username was claimed by another session", Code: api.CodeAuthRequired})
+		}
+	}
+	session := *p.session
+	s.send(r.c, api.AckEvent{RequestId: r.requestId, Session: &session})
+}
+
+// addHeat raises p's heat, capped by the rules
+func (s *Server) addHeat(p *player, amount int) {
+	p.state.Heat = s.Rules.HeatAfter(p.state.Heat, amount)
+}
+
+func (s *Server) requestRoute(r *reply, p *player, to string) {
+	if !validUsername.MatchString(to) {
+		r.error("Invalid target username")
+		return
+	}
+	to = strings.ToLower(to)
+	if to == p.state.Username {
+		r.error("Cannot create route to yourself")
+		return
+	}
+
+	routeId := s.nextId(p.state.Username + "-" + to)
+	s.requests[routeId] = routeRequest{from: p.state.Username, to: to}
+	s.broadcast(s.player(to), api.RouteRequestEvent{From: p.state.Username, RouteId: routeId})
+	r.ack.RouteId = routeId
+}
+
+func (s *Server) acceptRoute(r *reply, p *player, routeId string) {
+	req, ok := s.requests[routeId]
+	if !ok || req.to != p.state.Username {
+		r.error("Route request not found")
+		return
+	}
+	delete(s.requests, routeId)
+	s.openRoute(routeId, s.player(req.from), p)
+}
+
+// answerRoute rejects a request made to p, or withdraws one p made
+func (s *Server) answerRoute(r *reply, p *player, routeId string, withdraw bool) {
+	req, ok := s.requests[routeId]
+	if !ok || (withdraw && req.from != p.state.Username) || (!withdraw && req.to != p.state.Username) {
+		r.error("Route request not found")
+		return
+	}
+	delete(s.requests, routeId)
+
+	var ev api.Event = api.RouteRejectedEvent{RouteId: routeId}
+	if withdraw {
+		ev = api.RouteWithdrawnEvent{RouteId: routeId}
+	}
+	s.broadcast(s.player(req.from), ev)
+	s.broadcast(s.player(req.to), ev)
+}
+
+// openRoute creates an active route from a to b, tells both and looks for
+// POIs where it crosses other routes
+func (s *Server) openRoute(routeId string, a, b *player) api.RouteState {
+	route := &api.RouteState{
+		Id:        routeId,
+		PlayerA:   a.state.Username,
+		PlayerB:   b.state.Username,
+		CoordsA:   a.state.Coordinates,
+		CoordsB:   b.state.Coordinates,
+		Capacity:  s.Rules.DefaultCapacity,
+		Status:    "active",
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	s.routes[routeId] = route
+	a.state.Routes = append(a.state.Routes, routeId)
+	b.state.Routes = append(b.state.Routes, routeId)
+
+	ev := api.RouteAcceptedEvent{RouteId: routeId, Route: *route}
+	s.broadcast(a, ev)
+	s.broadcast(b, ev)
+
+	s.findIntersections(route)
+	return *route
+}
+
+// findIntersections creates a POI wherever route crosses a route that
+// shares no endpoint with it, and tells every player on either route
+func (s *Server) findIntersections(route *api.RouteState) {
+	ids := make([]string, 0, len(s.routes))
+	for id := range s.routes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		other := s.routes[id]
+		if other.Id == route.Id || sharesEndpoint(route, other) {
+			continue
+		}
+		at, ok := geo.LineIntersection(route.CoordsA, route.CoordsB, other.CoordsA, other.CoordsB)
+		if !ok {
+			continue
+		}
+
+		custody := []string{route.PlayerA, route.PlayerB, other.PlayerA, other.PlayerB}
+		sort.Strings(custody)
+		now := time.Now().UnixMilli()
+		poi := &api.IntersectionState{
+			Id:           s.nextId("poi"),
+			Coordinates:  at,
+			Routes:       []string{route.Id, other.Id},
+			Custody:      custody,
+			Investments:  make(map[string]int),
+			LastActivity: now,
+			CreatedAt:    now,
+		}
+		s.pois[poi.Id] = poi
+
+		for _, name := range custody {
+			p := s.player(name)
+			p.known = append(p.known, poi.Id)
+			s.broadcast(p, api.IntersectionCreatedEvent{Intersection: *poi})
+		}
+	}
+}
+
+func sharesEndpoint(a, b *api.RouteState) bool {
+	return a.PlayerA == b.PlayerA || a.PlayerA == b.PlayerB ||
+		a.PlayerB == b.PlayerA || a.PlayerB == b.PlayerB
+}
+
+func (s *Server) upgradeRoute(r *reply, p *player, routeId string) {
+	if p.state.Nits < s.Rules.UpgradeCost {
+		r.error("Insufficient nits (need %d)", s.Rules.UpgradeCost)
+		return
+	}
+	route, ok := s.routes[routeId]
+	if !ok || !contains(p.state.Routes, routeId) {
+		r.error("Not your route")
+		return
+	}
+
+	p.state.Nits -= s.Rules.UpgradeCost
+	s.addHeat(p, s.Rules.HeatUpgrade)
+	route.Capacity = s.Rules.Upgrade(route.Capacity)
+	s.broadcast(p, api.StateEvent{Player: p.state})
+}
+
+// investPoi adds to p's stake in a POI. The highest investor controls it
+// and a tie keeps the current controller.
+func (s *Server) investPoi(r *reply, p *player, poiId string, amount int) {
+	if amount <= 0 {
+		r.error("Investment amount must be positive")
+		return
+	}
+	if p.state.Nits < amount {
+		r.error("Insufficient nits")
+		return
+	}
+	// The real server charges for an unknown POI and acks; failing is kinder
+	// to tests
+	poi, ok := s.pois[poiId]
+	if !ok {
+		r.error("POI not found")
+		return
+	}
+
+	me := p.state.Username
+	p.state.Nits -= amount
+	p.state.PoiInvestments[poiId] += amount
+	s.addHeat(p, s.Rules.HeatInvest)
+
+	poi.Investments[me] += amount
+	poi.TotalInvested += amount
+	poi.LastActivity = time.Now().UnixMilli()
+	if !contains(poi.Custody, me) {
+		poi.Custody = append(poi.Custody, me)
+	}
+
+	previous := poi.Controller
+	poi.Controller = leader(poi.Investments, previous)
+	if poi.Controller != previous {
+		if previous != "" {
+			loser := s.player(previous)
+			loser.controlled = remove(loser.controlled, poiId)
+		}
+		winner := s.player(poi.Controller)
+		winner.controlled = append(winner.controlled, poiId)
+		s.addHeat(winner, s.Rules.HeatWin)
+		s.broadcast(winner, api.HeatUpdateEvent{Heat: winner.state.Heat})
+	}
+
+	if previous != "" && previous != me {
+		var controller *string
+		if poi.Controller != "" {
+			name := poi.Controller
+			controller = &name
+		}
+		defender := s.player(previous)
+		s.broadcast(defender, api.PoiContestEvent{PoiId: poiId, Attacker: me, Amount: amount, NewController: controller})
+		s.broadcast(defender, api.PoiUpdateEvent{Poi: *poi})
+	}
+
+	s.broadcast(p, api.PoiUpdateEvent{Poi: *poi})
+	s.broadcast(p, api.HeatUpdateEvent{Heat: p.state.Heat})
+}
+
+// leader returns the highest investor; current wins ties, then the first
+// name alphabetically
+func leader(investments map[string]int, current string) string {
+	names := make([]string, 0, len(investments))
+	for name := range investments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, top := current, investments[current]
+	for _, name := range names {
+		if investments[name] > top {
+			best, top = name, investments[name]
+		}
+	}
+	return best
+}
+
+// placeOrder matches an order against the book, rests what's left and
+// reports fills to both sides. Asks escrow their nits; a buyer receives
+// the nits it bought.
+func (s *Server) placeOrder(r *reply, p *player, side string, price float64, amount int) {
+	if side != "bid" && side != "ask" {
+		r.error("Invalid side %q", side)
+		return
+	}
+	if side == "ask" && p.state.Nits < amount {
+		r.error("Insufficient nits")
+		return
+	}
+	if side == "ask" {
+		p.state.Nits -= amount
+	}
+
+	order := api.MarketOrder{
+		Id:        s.nextId(p.state.Username),
+		Player:    p.state.Username,
+		Side:      side,
+		Price:     price,
+		Amount:    amount,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+
+	type fill struct {
+		amount int
+		price  float64
+	}
+	var fills []fill
+
+	book := &s.asks
+	crosses := func(resting float64) bool { return resting <= price }
+	if side == "ask" {
+		book = &s.bids
+		crosses = func(resting float64) bool { return resting >= price }
+	}
+	for order.Amount > 0 && len(*book) > 0 && crosses((*book)[0].Price) {
+		best := &(*book)[0]
+		n := min(order.Amount, best.Amount)
+		fills = append(fills, fill{n, best.Price})
+		order.Amount -= n
+		best.Amount -= n
+
+		s.lastPrice = best.Price
+		s.history = append(s.history, api.PricePoint{Timestamp: time.Now().UnixMilli(), Price: best.Price})
+
+		counterparty := s.player(best.Player)
+		buyer := p
+		if side == "ask" {
+			buyer = counterparty
+		}
+		s.transfer(buyer, n)
+		s.notifyFill(counterparty, best.Id, n, best.Price, best.Side)
+
+		if best.Amount == 0 {
+			*book = (*book)[1:]
+		}
+	}
+	if len(s.history) > 1000 {
+		s.history = s.history[len(s.history)-1000:]
+	}
+
+	if order.Amount > 0 {
+		if side == "bid" {
+			s.bids = insertOrder(s.bids, order, func(a, b float64) bool { return a > b })
+		} else {
+			s.asks = insertOrder(s.asks, order, func(a, b float64) bool { return a < b })
+		}
+	}
+	for _, f := range fills {
+		s.notifyFill(p, order.Id, f.amount, f.price, side)
+	}
+
+	s.addHeat(p, s.Rules.HeatTrade)
+	s.broadcast(p, api.HeatUpdateEvent{Heat: p.state.Heat})
+	r.ack.OrderId = order.Id
+}
+
+// insertOrder rests order behind every order at a price at least as good
+func insertOrder(book []api.MarketOrder, order api.MarketOrder, better func(a, b float64) bool) []api.MarketOrder {
+	i := sort.Search(len(book), func(i int) bool { return better(order.Price, book[i].Price) })
+	book = append(book, api.MarketOrder{})
+	copy(book[i+1:], book[i:])
+	book[i] = order
+	return book
+}
+
+// transfer credits p with nits and sends its new state
+func (s *Server) transfer(p *player, amount int) {
+	p.state.Nits += amount
+	s.broadcast(p, api.StateEvent{Player: p.state})
+}
+
+// notifyFill adds the per-fill trade heat and reports the fill
+func (s *Server) notifyFill(p *player, orderId string, amount int, price float64, side string) {
+	s.addHeat(p, s.Rules.HeatTrade)
+	s.broadcast(p, api.HeatUpdateEvent{Heat: p.state.Heat})
+	s.broadcast(p, api.OrderFilledEvent{OrderId: orderId, Amount: amount, Price: price, Side: side})
+}
+
+func (s *Server) cancelOrder(r *reply, p *player, orderId string) {
+	for i, o := range s.bids {
+		if o.Id == orderId && o.Player == p.state.Username {
+			s.bids = append(s.bids[:i], s.bids[i+1:]...)
+			return
+		}
+	}
+	for i, o := range s.asks {
+		if o.Id == orderId && o.Player == p.state.Username {
+			s.asks = append(s.asks[:i], s.asks[i+1:]...)
+			s.transfer(p, o.Amount) // Return the escrow
+			return
+		}
+	}
+	r.error("Order not found")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
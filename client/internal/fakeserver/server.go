@@ -0,0 +1,453 @@
+// Package fakeserver is an in-process stand-in for the foam server. It
+// speaks the WebSocket protocol at /ws/:username and serves GET /market and
+// the /auth endpoints, backed by one in-memory game, so client code can be
+// tested without wrangler. Time only passes when the test calls Tick.
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/rules"
+)
+
+// DefaultCoordinates is where players appear unless AddPlayer places them;
+// the real server uses the request's location instead
+var DefaultCoordinates = api.Coordinates{Lat: 40.7128, Lng: -74.0060}
+
+// sessionTTL matches the real server's token lifetime
+const sessionTTL = 24 * time.Hour
+
+var validUsername = regexp.MustCompile(`^[a-zA-Z0-9]{1,7}$`)
+
+// Server is a running fake foam server
+type Server struct {
+	URL   string       // WebSocket base URL for api.NewClient, ending in /ws
+	Rules rules.Params // Economy the game plays by
+
+	// Intercept, if set, sees every client message (pings aside) before the
+	// game does; returning true swallows it, e.g. to hold back an ack or to
+	// answer with Send instead. Set it before clients connect.
+	Intercept func(username string, msg api.ClientMessage) bool
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu        sync.Mutex // Guards everything below, and serializes writes to every conn
+	seq       int
+	players   map[string]*player
+	requests  map[string]routeRequest
+	routes    map[string]*api.RouteState
+	pois      map[string]*api.IntersectionState
+	bids      []api.MarketOrder // Highest price first
+	asks      []api.MarketOrder // Lowest price first
+	history   []api.PricePoint
+	lastPrice float64
+}
+
+// player is one username's state and open connections
+type player struct {
+	state      api.PlayerState
+	frac       float64  // Production below one nit, carried to the next tick
+	controlled []string // POIs we control, in the order we took them
+	known      []string // POIs created on our routes
+	session    *api.Session
+	conns      map[*conn]bool
+}
+
+// conn is one WebSocket connection
+type conn struct {
+	ws       *websocket.Conn
+	username string
+	tokenOK  bool // A valid session token came with the handshake or auth
+	authed   bool // The auth message succeeded
+}
+
+// routeRequest is a route request awaiting its answer
+type routeRequest struct {
+	from, to string
+}
+
+// New starts a fake server on a local port
+func New() *Server {
+	s := &Server{
+		Rules:     rules.Current,
+		players:   make(map[string]*player),
+		requests:  make(map[string]routeRequest),
+		routes:    make(map[string]*api.RouteState),
+		pois:      make(map[string]*api.IntersectionState),
+		lastPrice: 1,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/", s.handleWebSocket)
+	mux.HandleFunc("GET /market", s.handleMarket)
+	mux.HandleFunc("POST /auth/claim", s.handleClaim)
+	mux.HandleFunc("POST /auth/refresh", s.handleRefresh)
+
+	s.srv = httptest.NewServer(mux)
+	s.URL = "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws"
+	return s
+}
+
+// Close drops every connection and stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, p := range s.players {
+		for c := range p.conns {
+			c.ws.Close()
+		}
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// AddPlayer creates or replaces a player, e.g. to place it on the map or
+// give it nits before it connects
+func (s *Server) AddPlayer(state api.PlayerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Username = strings.ToLower(state.Username)
+	if state.Routes == nil {
+		state.Routes = []string{}
+	}
+	if state.PoiInvestments == nil {
+		state.PoiInvestments = make(map[string]int)
+	}
+	s.player(state.Username).state = state
+}
+
+// Player returns a copy of a player's current state
+func (s *Server) Player(username string) (api.PlayerState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.players[strings.ToLower(username)]
+	if !ok {
+		return api.PlayerState{}, false
+	}
+	state := p.state
+	state.Routes = append([]string{}, state.Routes...)
+	state.PoiInvestments = maps.Clone(state.PoiInvestments)
+	return state, true
+}
+
+// Poi returns a copy of a POI's current state
+func (s *Server) Poi(id string) (api.IntersectionState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poi, ok := s.pois[id]
+	if !ok {
+		return api.IntersectionState{}, false
+	}
+	out := *poi
+	out.Routes = append([]string{}, poi.Routes...)
+	out.Custody = append([]string{}, poi.Custody...)
+	out.Investments = maps.Clone(poi.Investments)
+	return out, true
+}
+
+// Pois returns copies of every POI, by id
+func (s *Server) Pois() []api.IntersectionState {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.pois))
+	for id := range s.pois {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(ids)
+	out := make([]api.IntersectionState, 0, len(ids))
+	for _, id := range ids {
+		poi, _ := s.Poi(id)
+		out = append(out, poi)
+	}
+	return out
+}
+
+// AddRoute opens a route between two players as if one had accepted the
+// other's request, creating either player if needed
+func (s *Server) AddRoute(a, b string) api.RouteState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openRoute(s.nextId(strings.ToLower(a)+"-"+strings.ToLower(b)), s.player(a), s.player(b))
+}
+
+// Send pushes an event to every connection of username, as if the server
+// had sent it
+func (s *Server) Send(username string, ev api.Event) error {
+	frame, err := encode(ev)
+	if err != nil {
+		return err
+	}
+	s.SendRaw(username, frame)
+	return nil
+}
+
+// SendRaw pushes a frame as is, e.g. to test malformed or unknown messages
+func (s *Server) SendRaw(username string, frame []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.players[strings.ToLower(username)]; ok {
+		for c := range p.conns {
+			c.write(frame)
+		}
+	}
+}
+
+// Disconnect drops every connection of username, as a network failure would
+func (s *Server) Disconnect(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.players[strings.ToLower(username)]; ok {
+		for c := range p.conns {
+			c.ws.Close()
+		}
+	}
+}
+
+// ExpireSession expires a claimed username's session token, so its next
+// auth gets auth_expired
+func (s *Server) ExpireSession(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.players[strings.ToLower(username)]; ok && p.session != nil {
+		p.session.ExpiresAt = time.Now().Add(-time.Minute).UnixMilli()
+	}
+}
+
+// Connections returns how many connections username has open
+func (s *Server) Connections(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.players[strings.ToLower(username)]; ok {
+		return len(p.conns)
+	}
+	return 0
+}
+
+// player returns the named player, creating it with starting state
+func (s *Server) player(username string) *player {
+	username = strings.ToLower(username)
+	p, ok := s.players[username]
+	if !ok {
+		p = &player{
+			state: api.PlayerState{
+				Username:       username,
+				Nits:           100,
+				ProductionRate: 1,
+				Coordinates:    DefaultCoordinates,
+				City:           "Unknown",
+				Region:         "Unknown",
+				Country:        "US",
+				CreatedAt:      time.Now().UnixMilli(),
+				Routes:         []string{},
+				PoiInvestments: make(map[string]int),
+			},
+			conns: make(map[*conn]bool),
+		}
+		s.players[username] = p
+	}
+	return p
+}
+
+// sortedPlayers returns the players by username, for deterministic ticks
+func (s *Server) sortedPlayers() []*player {
+	out := make([]*player, 0, len(s.players))
+	for _, p := range s.players {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].state.Username < out[j].state.Username })
+	return out
+}
+
+// nextId returns a unique id with the given prefix
+func (s *Server) nextId(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s-%d", prefix, s.seq)
+}
+
+// broadcast sends ev to every authorized connection of p; the others get
+// nothing but their auth error
+func (s *Server) broadcast(p *player, ev api.Event) {
+	frame, err := encode(ev)
+	if err != nil {
+		panic(err) // Our own events always encode
+	}
+	for c := range p.conns {
+		if p.authorized(c) {
+			c.write(frame)
+		}
+	}
+}
+
+// write sends one frame; a dead connection is noticed by its read loop
+func (c *conn) write(frame []byte) {
+	c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.ws.WriteMessage(websocket.TextMessage, frame)
+}
+
+// encode marshals ev with its wire type
+func encode(ev api.Event) ([]byte, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage)
+	}
+	typ, _ := json.Marshal(ev.Type())
+	fields["type"] = typ
+	return json.Marshal(fields)
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimPrefix(r.URL.Path, "/ws/")
+	if !validUsername.MatchString(username) {
+		http.Error(w, "Invalid username", http.StatusBadRequest)
+		return
+	}
+	username = strings.ToLower(username)
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws, username: username}
+
+	s.mu.Lock()
+	p := s.player(username)
+	p.conns[c] = true
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	c.tokenOK = p.checkToken(token) == ""
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(p.conns, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.handleFrame(c, data)
+	}
+}
+
+func (s *Server) handleMarket(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	snap := api.MarketSnapshot{
+		Bids:         append([]api.MarketOrder{}, s.bids...),
+		Asks:         append([]api.MarketOrder{}, s.asks...),
+		PriceHistory: append([]api.PricePoint{}, s.history...),
+		LastPrice:    s.lastPrice,
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap)
+}
+
+func (s *Server) handleClaim(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username string `json:"username"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil || !validUsername.MatchString(body.Username) {
+		http.Error(w, "Invalid username", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.players[strings.ToLower(body.Username)]; ok {
+		if p.session != nil {
+			http.Error(w, "Username already claimed", http.StatusConflict)
+		} else {
+			http.Error(w, "Player exists; claim from a connected session", http.StatusForbidden)
+		}
+		return
+	}
+	s.issueSession(w, s.player(body.Username), s.nextId("refresh"))
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username     string `json:"username"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil || !validUsername.MatchString(body.Username) {
+		http.Error(w, "Invalid username", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.players[strings.ToLower(body.Username)]
+	if !ok || p.session == nil || body.RefreshToken == "" || body.RefreshToken != p.session.RefreshToken {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	s.issueSession(w, p, body.RefreshToken)
+}
+
+// issueSession stores and returns a fresh token alongside refreshToken
+func (s *Server) issueSession(w http.ResponseWriter, p *player, refreshToken string) {
+	p.session = s.newSession(p, refreshToken)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.session)
+}
+
+// newSession returns a fresh token for p alongside refreshToken
+func (s *Server) newSession(p *player, refreshToken string) *api.Session {
+	return &api.Session{
+		Username:     p.state.Username,
+		Token:        s.nextId("token"),
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(sessionTTL).UnixMilli(),
+	}
+}
+
+// authorized reports whether c may act as p and see its stream: p is
+// unclaimed, or c presented a token that has not expired since
+func (p *player) authorized(c *conn) bool {
+	return c.tokenOK && (p.session == nil || time.Now().UnixMilli() <= p.session.ExpiresAt)
+}
+
+// checkToken returns "" if token opens p, or the error code to send.
+// Unclaimed usernames need no token.
+func (p *player) checkToken(token string) string {
+	switch {
+	case p.session == nil:
+		return ""
+	case token == "" || token != p.session.Token:
+		return api.CodeAuthRequired
+	case time.Now().UnixMilli() > p.session.ExpiresAt:
+		return api.CodeAuthExpired
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,54 @@
+// Package geo mirrors the server's geographic helpers (server/src/lib/geo.ts)
+// so the client computes the same distances and crossings.
+package geo
+
+import (
+	"math"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// EarthRadiusKm is the mean radius used by the server
+const EarthRadiusKm = 6371
+
+// Distance returns the great-circle distance in km (Haversine formula)
+func Distance(a, b api.Coordinates) float64 {
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+	lat1 := toRad(a.Lat)
+	lat2 := toRad(b.Lat)
+
+	h := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// LineIntersection returns where segments a1-a2 and b1-b2 cross, treating
+// lng/lat as planar x/y exactly as the server does
+func LineIntersection(a1, a2, b1, b2 api.Coordinates) (api.Coordinates, bool) {
+	x1, y1 := a1.Lng, a1.Lat
+	x2, y2 := a2.Lng, a2.Lat
+	x3, y3 := b1.Lng, b1.Lat
+	x4, y4 := b2.Lng, b2.Lat
+
+	denom := (x1-x2)*(y3-y4) - (y1-y2)*(x3-x4)
+	if math.Abs(denom) < 1e-10 {
+		return api.Coordinates{}, false // Lines are parallel
+	}
+
+	t := ((x1-x3)*(y3-y4) - (y1-y3)*(x3-x4)) / denom
+	u := -((x1-x2)*(y1-y3) - (y1-y2)*(x1-x3)) / denom
+
+	if t >= 0 && t <= 1 && u >= 0 && u <= 1 {
+		return api.Coordinates{
+			Lng: x1 + t*(x2-x1),
+			Lat: y1 + t*(y2-y1),
+		}, true
+	}
+	return api.Coordinates{}, false
+}
+
+func toRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,50 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type record struct {
+	N int `json:"n"`
+}
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+
+	if err := Read(path, func(record) { t.Error("read a record from a missing file") }); err != nil {
+		t.Fatalf("read missing file: %v", err)
+	}
+
+	for n := 1; n <= 2; n++ {
+		if err := Append(path, record{N: n}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{truncated\n")
+	f.Close()
+	if err := Append(path, record{N: 3}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	var got []int
+	if err := Read(path, func(r record) { got = append(got, r.N) }); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("read %v, want [1 2 3] past the bad line", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("file mode = %v, want 0600", perm)
+	}
+}
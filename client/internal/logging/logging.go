@@ -0,0 +1,182 @@
+// Package logging builds the client's slog handlers: a log file and an
+// in-memory ring the TUI's log pane reads from.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Open appends text records at level and above to the file at path
+func Open(path string, level slog.Leveler) (slog.Handler, *os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.NewTextHandler(f, &slog.HandlerOptions{Level: level}), f, nil
+}
+
+// Record is one formatted entry in a Ring
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   string // key=value pairs, space separated
+}
+
+// ringBuffer is the storage a Ring and the handlers derived from it share
+type ringBuffer struct {
+	mu      sync.Mutex
+	records []Record
+	next    int // Slot the next record goes in once records is full
+	size    int
+}
+
+// Ring is a handler that keeps the most recent records in memory. It is
+// safe for concurrent use.
+type Ring struct {
+	buf    *ringBuffer
+	level  slog.Leveler
+	attrs  string // Formatted attrs from WithAttrs
+	prefix string // Group prefix from WithGroup, with a trailing dot
+}
+
+// NewRing keeps the last size records at level and above
+func NewRing(size int, level slog.Leveler) *Ring {
+	return &Ring{buf: &ringBuffer{size: size}, level: level}
+}
+
+func (r *Ring) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= r.level.Level()
+}
+
+func (r *Ring) Handle(_ context.Context, rec slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.attrs)
+	rec.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, r.prefix, a)
+		return true
+	})
+	r.buf.add(Record{
+		Time:    rec.Time,
+		Level:   rec.Level,
+		Message: rec.Message,
+		Attrs:   strings.TrimSpace(b.String()),
+	})
+	return nil
+}
+
+func (r *Ring) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(r.attrs)
+	for _, a := range attrs {
+		appendAttr(&b, r.prefix, a)
+	}
+	return &Ring{buf: r.buf, level: r.level, attrs: b.String(), prefix: r.prefix}
+}
+
+func (r *Ring) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	return &Ring{buf: r.buf, level: r.level, attrs: r.attrs, prefix: r.prefix + name + "."}
+}
+
+// Records returns the kept records, oldest first
+func (r *Ring) Records() []Record {
+	return r.buf.snapshot()
+}
+
+func (b *ringBuffer) add(rec Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.records) < b.size {
+		b.records = append(b.records, rec)
+		return
+	}
+	b.records[b.next] = rec
+	b.next = (b.next + 1) % b.size
+}
+
+func (b *ringBuffer) snapshot() []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Record, 0, len(b.records))
+	out = append(out, b.records[b.next:]...)
+	return append(out, b.records[:b.next]...)
+}
+
+// appendAttr writes a as " key=value", flattening groups
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, g := range a.Value.Group() {
+			appendAttr(b, prefix, g)
+		}
+		return
+	}
+	value := a.Value.String()
+	if strings.ContainsAny(value, " =\"") || value == "" {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
+
+// multi sends each record to every handler that wants it
+type multi []slog.Handler
+
+// Tee returns a handler that passes records to all of handlers
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return multi(handlers)
+}
+
+func (m multi) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multi) Handle(ctx context.Context, rec slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, rec.Level) {
+			errs = append(errs, h.Handle(ctx, rec.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multi) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(multi, len(m))
+	for i, h := range m {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (m multi) WithGroup(name string) slog.Handler {
+	out := make(multi, len(m))
+	for i, h := range m {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRingKeepsNewest(t *testing.T) {
+	ring := NewRing(3, slog.LevelInfo)
+	l := slog.New(ring).With("user", "al")
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		l.Info(msg, "n", len(msg))
+	}
+	l.Debug("hidden")
+
+	records := ring.Records()
+	var got []string
+	for _, r := range records {
+		got = append(got, r.Message)
+	}
+	if strings.Join(got, ",") != "two,three,four" {
+		t.Errorf("records = %v, want two,three,four", got)
+	}
+	if records[0].Attrs != "user=al n=3" {
+		t.Errorf("attrs = %q, want %q", records[0].Attrs, "user=al n=3")
+	}
+}
+
+func TestRingGroupsAndQuoting(t *testing.T) {
+	ring := NewRing(1, slog.LevelInfo)
+	slog.New(ring).WithGroup("req").Info("sent", "type", "auth", slog.Group("ack", "msg", "two words"))
+
+	want := `req.type=auth req.ack.msg="two words"`
+	if got := ring.Records()[0].Attrs; got != want {
+		t.Errorf("attrs = %q, want %q", got, want)
+	}
+}
+
+func TestTeeLevels(t *testing.T) {
+	var buf bytes.Buffer
+	ring := NewRing(10, slog.LevelDebug)
+	file := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	l := slog.New(Tee(file, ring))
+
+	l.Debug("frame")
+	l.Warn("lost")
+
+	if n := len(ring.Records()); n != 2 {
+		t.Errorf("ring kept %d records, want 2", n)
+	}
+	if strings.Contains(buf.String(), "frame") || !strings.Contains(buf.String(), "lost") {
+		t.Errorf("file got %q, want only the warning", buf.String())
+	}
+}
@@ -0,0 +1,74 @@
+package market
+
+import (
+	"sort"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// Best returns the highest bid and lowest ask; ok is false for a side with
+// no orders
+func Best(bids, asks []api.MarketOrder) (bid float64, bidOK bool, ask float64, askOK bool) {
+	for _, o := range bids {
+		if !bidOK || o.Price > bid {
+			bid, bidOK = o.Price, true
+		}
+	}
+	for _, o := range asks {
+		if !askOK || o.Price < ask {
+			ask, askOK = o.Price, true
+		}
+	}
+	return bid, bidOK, ask, askOK
+}
+
+// Mid returns the midpoint of the best bid and ask
+func Mid(bids, asks []api.MarketOrder) (float64, bool) {
+	bid, bidOK, ask, askOK := Best(bids, asks)
+	if !bidOK || !askOK {
+		return 0, false
+	}
+	return (bid + ask) / 2, true
+}
+
+// Level is the aggregated size at one price
+type Level struct {
+	Price      float64
+	Size       int  // Total nits at this price
+	Orders     int  // Orders at this price
+	Cumulative int  // Size at this price and better
+	Mine       bool // One of the orders is ours
+}
+
+// Ladder aggregates the book into price levels: bids best (highest) first,
+// asks best (lowest) first, each with cumulative depth from the touch
+func Ladder(bids, asks []api.MarketOrder, me string) (bidLevels, askLevels []Level) {
+	return levels(bids, me, func(a, b float64) bool { return a > b }),
+		levels(asks, me, func(a, b float64) bool { return a < b })
+}
+
+func levels(orders []api.MarketOrder, me string, better func(a, b float64) bool) []Level {
+	index := make(map[float64]int)
+	var out []Level
+	for _, o := range orders {
+		i, ok := index[o.Price]
+		if !ok {
+			i = len(out)
+			index[o.Price] = i
+			out = append(out, Level{Price: o.Price})
+		}
+		out[i].Size += o.Amount
+		out[i].Orders++
+		if o.Player == me {
+			out[i].Mine = true
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return better(out[i].Price, out[j].Price) })
+	cum := 0
+	for i := range out {
+		cum += out[i].Size
+		out[i].Cumulative = cum
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+// Package market holds client-side views of the global market: the price
+// series and order book aggregation.
+package market
+
+import (
+	"sort"
+	"time"
+)
+
+// maxPoints bounds the series, matching the server's 1000-entry history
+const maxPoints = 1000
+
+// Point is one price at a moment: a trade, or the book's mid
+type Point struct {
+	Time  time.Time
+	Price float64
+}
+
+// Candle summarizes the trades in one time bucket
+type Candle struct {
+	Start                  time.Time
+	Open, High, Low, Close float64
+	Trades                 int // 0 for an empty bucket
+}
+
+// Series is a time-ordered price history, of trades or of mids
+type Series struct {
+	points []Point
+}
+
+// Add appends a point, keeping the series ordered
+func (s *Series) Add(p Point) {
+	i := sort.Search(len(s.points), func(i int) bool { return s.points[i].Time.After(p.Time) })
+	s.points = append(s.points, Point{})
+	copy(s.points[i+1:], s.points[i:])
+	s.points[i] = p
+	s.trim()
+}
+
+// Backfill replaces everything up to the end of history with it, keeping
+// only points recorded locally since then. The server's history is
+// authoritative and already includes fills we saw live.
+func (s *Series) Backfill(history []Point) {
+	if len(history) == 0 {
+		return
+	}
+	sorted := append([]Point(nil), history...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	last := sorted[len(sorted)-1].Time
+	for _, p := range s.points {
+		if p.Time.After(last) {
+			sorted = append(sorted, p)
+		}
+	}
+	s.points = sorted
+	s.trim()
+}
+
+func (s *Series) trim() {
+	if len(s.points) > maxPoints {
+		s.points = s.points[len(s.points)-maxPoints:]
+	}
+}
+
+// Len returns the number of points
+func (s *Series) Len() int {
+	return len(s.points)
+}
+
+// Last returns the most recent price
+func (s *Series) Last() (Point, bool) {
+	if len(s.points) == 0 {
+		return Point{}, false
+	}
+	return s.points[len(s.points)-1], true
+}
+
+// Since returns the points at or after t
+func (s *Series) Since(t time.Time) []Point {
+	i := sort.Search(len(s.points), func(i int) bool { return !s.points[i].Time.Before(t) })
+	return s.points[i:]
+}
+
+// Tail returns up to the last n points
+func (s *Series) Tail(n int) []Point {
+	if n >= len(s.points) {
+		return s.points
+	}
+	return s.points[len(s.points)-n:]
+}
+
+// Candles buckets the window ending at end into n candles. Empty buckets
+// carry the previous close as a flat candle with no trades.
+func (s *Series) Candles(end time.Time, window time.Duration, n int) []Candle {
+	if n <= 0 {
+		return nil
+	}
+	start := end.Add(-window)
+	width := window / time.Duration(n)
+	if width <= 0 {
+		width = time.Nanosecond
+	}
+
+	candles := make([]Candle, n)
+	for i := range candles {
+		candles[i].Start = start.Add(time.Duration(i) * width)
+	}
+
+	// The last trade before the window opens the first bucket
+	prev, havePrev := 0.0, false
+	if i := sort.Search(len(s.points), func(i int) bool { return !s.points[i].Time.Before(start) }); i > 0 {
+		prev, havePrev = s.points[i-1].Price, true
+	}
+
+	for _, p := range s.Since(start) {
+		if p.Time.After(end) {
+			break
+		}
+		i := min(int(p.Time.Sub(start)/width), n-1)
+		c := &candles[i]
+		if c.Trades == 0 {
+			c.Open, c.High, c.Low = p.Price, p.Price, p.Price
+		}
+		c.High = max(c.High, p.Price)
+		c.Low = min(c.Low, p.Price)
+		c.Close = p.Price
+		c.Trades++
+	}
+
+	for i := range candles {
+		c := &candles[i]
+		if c.Trades == 0 {
+			if havePrev {
+				c.Open, c.High, c.Low, c.Close = prev, prev, prev, prev
+			}
+			continue
+		}
+		prev, havePrev = c.Close, true
+	}
+	return candles
+}
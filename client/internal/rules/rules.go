@@ -0,0 +1,208 @@
+// Package rules encodes the game economy from DESIGN.md, mirroring the
+// server's formulas, so the TUI, action previews and bots all compute the
+// same numbers. Parameters are versioned so a client can follow a server
+// that retunes them.
+package rules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Tier is a visibility band: the hotter we run, the further away we show up
+type Tier struct {
+	Name    string
+	MaxHeat int // Highest heat in the tier
+	Hops    int // Route hops we're visible across; 0 means everyone
+}
+
+// Params is one versioned set of economy parameters
+type Params struct {
+	Version string
+
+	// Production
+	TickInterval   time.Duration
+	BaseProduction int     // Nits per tick for a new HOME
+	POIBonus       float64 // Extra nits per tick for each POI we control
+
+	// Routes
+	TollRate        float64 // Share of route flow paid to a POI's controller
+	DefaultCapacity int
+	UpgradeCost     int // Nits per upgrade
+	UpgradeCapacity int // Capacity added per upgrade
+
+	// Heat
+	HeatMax         int
+	HeatDecay       int // Lost per tick
+	HeatInvest      int // Each POI investment
+	HeatWin         int // Taking control of a POI
+	HeatTrade       int // Placing an order, and again for each fill
+	HeatAttack      int // Attacking another player's POI (not yet applied by the server)
+	HeatUpgrade     int // Upgrading a route
+	Tiers           []Tier
+	RevealNitsAbove int // Heat above which others see our nits
+
+	// POI investment decay
+	POIDecayInterval time.Duration // Inactivity before investments decay
+	POIDecayRate     float64       // Share lost each interval
+}
+
+// V1 matches the constants in server/src/durable-objects
+var V1 = Params{
+	Version: "v1",
+
+	TickInterval:   10 * time.Second,
+	BaseProduction: 1,
+	POIBonus:       0.5,
+
+	TollRate:        0.10,
+	DefaultCapacity: 10,
+	UpgradeCost:     50,
+	UpgradeCapacity: 5,
+
+	HeatMax:     100,
+	HeatDecay:   1,
+	HeatInvest:  5,
+	HeatWin:     10,
+	HeatTrade:   2,
+	HeatAttack:  15,
+	HeatUpgrade: 3,
+	Tiers: []Tier{
+		{Name: "Low", MaxHeat: 25, Hops: 1},
+		{Name: "Moderate", MaxHeat: 50, Hops: 2},
+		{Name: "Hot", MaxHeat: 75, Hops: 3},
+		{Name: "Burning", MaxHeat: 100, Hops: 0},
+	},
+	RevealNitsAbove: 75,
+
+	POIDecayInterval: 5 * time.Minute,
+	POIDecayRate:     0.10,
+}
+
+// Current is the parameter set the server runs today
+var Current = V1
+
+var versions = map[string]Params{
+	V1.Version: V1,
+}
+
+// Lookup returns the parameter set for version; "" means Current
+func Lookup(version string) (Params, error) {
+	if version == "" {
+		return Current, nil
+	}
+	p, ok := versions[version]
+	if !ok {
+		return Params{}, fmt.Errorf("unknown rules version %q (expected one of %v)", version, Versions())
+	}
+	return p, nil
+}
+
+// Versions lists the known parameter sets
+func Versions() []string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Production is the nits a HOME earns per tick with controlled POIs
+func (p Params) Production(baseRate, controlled int) float64 {
+	return float64(baseRate) + float64(controlled)*p.POIBonus
+}
+
+// ProductionOver is the nits earned across d, counting whole ticks only
+func (p Params) ProductionOver(baseRate, controlled int, d time.Duration) float64 {
+	return float64(d/p.TickInterval) * p.Production(baseRate, controlled)
+}
+
+// Toll is the controller's cut of flow through a POI, rounded down
+func (p Params) Toll(flow int) int {
+	return int(math.Floor(float64(flow) * p.TollRate))
+}
+
+// Upgrade returns a route's capacity after one upgrade
+func (p Params) Upgrade(capacity int) int {
+	return capacity + p.UpgradeCapacity
+}
+
+// HeatAfter applies delta, clamped to [0, HeatMax] like the server
+func (p Params) HeatAfter(heat, delta int) int {
+	return max(0, min(p.HeatMax, heat+delta))
+}
+
+// InvestHeat is the heat an investment adds, including the bonus for
+// taking control
+func (p Params) InvestHeat(takesControl bool) int {
+	if takesControl {
+		return p.HeatInvest + p.HeatWin
+	}
+	return p.HeatInvest
+}
+
+// TradeHeat is the heat an order adds once placed and, if filled, after
+// its fill
+func (p Params) TradeHeat(filled bool) int {
+	if filled {
+		return 2 * p.HeatTrade
+	}
+	return p.HeatTrade
+}
+
+// TierIndex returns the index into Tiers for heat
+func (p Params) TierIndex(heat int) int {
+	for i, t := range p.Tiers {
+		if heat <= t.MaxHeat {
+			return i
+		}
+	}
+	return len(p.Tiers) - 1
+}
+
+// Tier returns the visibility tier for heat
+func (p Params) Tier(heat int) Tier {
+	return p.Tiers[p.TierIndex(heat)]
+}
+
+// CoolDown is how long passive decay takes to bring heat down to target
+func (p Params) CoolDown(heat, target int) time.Duration {
+	if heat <= target || p.HeatDecay <= 0 {
+		return 0
+	}
+	ticks := (heat - target + p.HeatDecay - 1) / p.HeatDecay
+	return time.Duration(ticks) * p.TickInterval
+}
+
+// TakesControl reports whether adding amount to player's investment makes
+// them the strict largest investor, which is how the server picks the
+// controller
+func (p Params) TakesControl(investments map[string]int, player string, amount int) bool {
+	if amount <= 0 {
+		return false
+	}
+	ours := investments[player] + amount
+	for other, invested := range investments {
+		if other != player && invested >= ours {
+			return false
+		}
+	}
+	return true
+}
+
+// DecayInvestment is an investment after one decay interval; the server
+// drops investments that reach zero
+func (p Params) DecayInvestment(amount int) int {
+	return int(math.Floor(float64(amount) * (1 - p.POIDecayRate)))
+}
+
+// DecayAfter is an investment after a POI sits idle for d
+func (p Params) DecayAfter(amount int, idle time.Duration) int {
+	for n := idle / p.POIDecayInterval; n > 0 && amount > 0; n-- {
+		amount = p.DecayInvestment(amount)
+	}
+	return amount
+}
@@ -0,0 +1,151 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	p, err := Lookup("")
+	if err != nil || p.Version != Current.Version {
+		t.Fatalf("Lookup(\"\") = %q, %v; want current %q", p.Version, err, Current.Version)
+	}
+	if _, err := Lookup("v1"); err != nil {
+		t.Fatalf("Lookup(v1): %v", err)
+	}
+	if _, err := Lookup("v0"); err == nil {
+		t.Fatal("Lookup(v0) succeeded, want error")
+	}
+}
+
+func TestProduction(t *testing.T) {
+	tests := []struct {
+		base, controlled int
+		want             float64
+	}{
+		{1, 0, 1},
+		{1, 1, 1.5},
+		{1, 4, 3},
+		{3, 2, 4},
+	}
+	for _, tt := range tests {
+		if got := V1.Production(tt.base, tt.controlled); got != tt.want {
+			t.Errorf("Production(%d, %d) = %v, want %v", tt.base, tt.controlled, got, tt.want)
+		}
+	}
+
+	if got := V1.ProductionOver(1, 1, 95*time.Second); got != 13.5 {
+		t.Errorf("ProductionOver 95s = %v, want 13.5 (9 whole ticks)", got)
+	}
+}
+
+func TestToll(t *testing.T) {
+	tests := []struct{ flow, want int }{
+		{0, 0},
+		{9, 0},
+		{10, 1},
+		{55, 5},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if got := V1.Toll(tt.flow); got != tt.want {
+			t.Errorf("Toll(%d) = %d, want %d", tt.flow, got, tt.want)
+		}
+	}
+}
+
+func TestHeat(t *testing.T) {
+	tests := []struct {
+		heat, delta, want int
+	}{
+		{0, 5, 5},
+		{98, 5, 100},
+		{0, -1, 0},
+		{40, V1.InvestHeat(true), 55},
+		{40, V1.TradeHeat(true), 44},
+	}
+	for _, tt := range tests {
+		if got := V1.HeatAfter(tt.heat, tt.delta); got != tt.want {
+			t.Errorf("HeatAfter(%d, %d) = %d, want %d", tt.heat, tt.delta, got, tt.want)
+		}
+	}
+}
+
+func TestTier(t *testing.T) {
+	tests := []struct {
+		heat int
+		want string
+	}{
+		{0, "Low"},
+		{25, "Low"},
+		{26, "Moderate"},
+		{50, "Moderate"},
+		{51, "Hot"},
+		{75, "Hot"},
+		{76, "Burning"},
+		{100, "Burning"},
+	}
+	for _, tt := range tests {
+		if got := V1.Tier(tt.heat).Name; got != tt.want {
+			t.Errorf("Tier(%d) = %s, want %s", tt.heat, got, tt.want)
+		}
+	}
+}
+
+func TestCoolDown(t *testing.T) {
+	tests := []struct {
+		heat, target int
+		want         time.Duration
+	}{
+		{58, 50, 80 * time.Second},
+		{30, 25, 50 * time.Second},
+		{20, 25, 0},
+		{100, 0, 1000 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := V1.CoolDown(tt.heat, tt.target); got != tt.want {
+			t.Errorf("CoolDown(%d, %d) = %v, want %v", tt.heat, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestTakesControl(t *testing.T) {
+	investments := map[string]int{"al": 30, "bob": 10}
+	tests := []struct {
+		player string
+		amount int
+		want   bool
+	}{
+		{"bob", 20, false}, // A tie keeps the current controller
+		{"bob", 21, true},
+		{"cy", 31, true},
+		{"cy", 0, false},
+		{"al", 1, true},
+	}
+	for _, tt := range tests {
+		if got := V1.TakesControl(investments, tt.player, tt.amount); got != tt.want {
+			t.Errorf("TakesControl(%s, %d) = %v, want %v", tt.player, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestDecay(t *testing.T) {
+	if got := V1.DecayInvestment(100); got != 90 {
+		t.Errorf("DecayInvestment(100) = %d, want 90", got)
+	}
+	if got := V1.DecayInvestment(5); got != 4 {
+		t.Errorf("DecayInvestment(5) = %d, want 4 (rounded down)", got)
+	}
+	if got := V1.DecayAfter(100, 4*time.Minute); got != 100 {
+		t.Errorf("DecayAfter(100, 4m) = %d, want 100", got)
+	}
+	if got := V1.DecayAfter(100, 15*time.Minute); got != 72 {
+		t.Errorf("DecayAfter(100, 15m) = %d, want 72", got)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	if got := V1.Upgrade(V1.DefaultCapacity); got != 15 {
+		t.Errorf("Upgrade(%d) = %d, want 15", V1.DefaultCapacity, got)
+	}
+}
@@ -0,0 +1,27 @@
+package trades
+
+import (
+	"strings"
+
+	"github.com/philip/foam/internal/jsonl"
+)
+
+// AppendFill adds f to the JSONL history at path
+func AppendFill(path string, f Fill) error {
+	return jsonl.Append(path, f)
+}
+
+// LoadFills reads the history at path, keeping fills for username on
+// server. Unreadable lines are skipped so one bad write can't hide the rest.
+func LoadFills(path, server, username string) ([]Fill, error) {
+	var fills []Fill
+	err := jsonl.Read(path, func(f Fill) {
+		if f.Server == server && strings.EqualFold(f.Username, username) {
+			fills = append(fills, f)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fills, nil
+}
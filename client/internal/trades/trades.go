@@ -0,0 +1,263 @@
+// Package trades tracks our own market orders, their fills and the
+// resulting position and P&L.
+package trades
+
+import (
+	"sort"
+	"time"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// Order statuses
+const (
+	StatusOpen      = "open"
+	StatusPartial   = "partial"
+	StatusFilled    = "filled"
+	StatusCancelled = "cancelled"
+	StatusGone      = "gone" // Left the book without us seeing why
+)
+
+// Order is one of our orders and how much of it has filled
+type Order struct {
+	Id       string
+	Side     string // "bid" or "ask"
+	Price    float64
+	Amount   int // Original size
+	Filled   int
+	Status   string
+	PlacedAt time.Time
+}
+
+// Remaining is the unfilled size
+func (o Order) Remaining() int {
+	return o.Amount - o.Filled
+}
+
+// Active reports whether the order can still fill
+func (o Order) Active() bool {
+	return o.Status == StatusOpen || o.Status == StatusPartial
+}
+
+// Fill is one execution of one of our orders
+type Fill struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Username string    `json:"username"`
+	OrderId  string    `json:"orderId"`
+	Side     string    `json:"side"`
+	Amount   int       `json:"amount"`
+	Price    float64   `json:"price"`
+}
+
+// Stats summarizes our trading. Nits sold beyond what we bought came from
+// production and carry no cost, so their whole price counts as profit.
+type Stats struct {
+	Bought   int     // Nits bought
+	Sold     int     // Nits sold
+	Position int     // Bought nits still held
+	AvgCost  float64 // Average price of the held position
+	Realized float64 // Realized P&L from sales against AvgCost
+}
+
+// Book is our side of the market
+type Book struct {
+	orders map[string]*Order
+	early  map[string][]Fill // Fills for orders not placed yet, by order id
+	fills  []Fill
+	stats  Stats
+}
+
+// NewBook returns an empty Book
+func NewBook() *Book {
+	return &Book{orders: make(map[string]*Order), early: make(map[string][]Fill)}
+}
+
+// Place records an order the server acknowledged. The market sends a fill
+// before the ack, so fills Apply held for this order count towards it.
+func (b *Book) Place(o Order) {
+	if o.Status == "" {
+		o.Status = StatusOpen
+	}
+	if existing, ok := b.orders[o.Id]; ok {
+		o.Filled = existing.Filled
+		o.Status = existing.Status
+	}
+	b.orders[o.Id] = &o
+
+	early := b.early[o.Id]
+	delete(b.early, o.Id)
+	for _, f := range early {
+		b.fill(&o, f.Amount)
+		if f.Side == "" {
+			// Left out of the P&L until we knew which way it went
+			f.Side = o.Side
+			b.record(f)
+		}
+	}
+}
+
+// Cancel marks an order cancelled
+func (b *Book) Cancel(id string) {
+	if o, ok := b.orders[id]; ok && o.Active() {
+		o.Status = StatusCancelled
+	}
+}
+
+// Sync reconciles our orders with the book: resting orders of ours we
+// didn't know about are adopted, and active orders missing from the book
+// are marked gone (filled while we weren't watching, or cancelled
+// elsewhere). A snapshot fetched before an order was placed can mark it
+// gone wrongly, so a gone order that shows up on the book again is revived.
+func (b *Book) Sync(username string, bids, asks []api.MarketOrder) {
+	onBook := make(map[string]bool)
+	for _, side := range [][]api.MarketOrder{bids, asks} {
+		for _, mo := range side {
+			if mo.Player != username {
+				continue
+			}
+			onBook[mo.Id] = true
+
+			o, ok := b.orders[mo.Id]
+			if !ok {
+				o = &Order{
+					Id:       mo.Id,
+					Side:     mo.Side,
+					Price:    mo.Price,
+					Amount:   mo.Amount,
+					Status:   StatusOpen,
+					PlacedAt: time.UnixMilli(mo.CreatedAt),
+				}
+				b.orders[mo.Id] = o
+				// The book's remaining size covers fills held for it
+				for _, f := range b.early[mo.Id] {
+					if f.Side == "" {
+						f.Side = mo.Side
+						b.record(f)
+					}
+				}
+				delete(b.early, mo.Id)
+			}
+			// The book holds the remaining size
+			if filled := o.Amount - mo.Amount; filled > o.Filled {
+				o.Filled = filled
+				if o.Active() {
+					o.Status = StatusPartial
+				}
+			}
+			if o.Status == StatusGone {
+				o.Status = StatusOpen
+				if o.Filled > 0 {
+					o.Status = StatusPartial
+				}
+			}
+		}
+	}
+
+	for id, o := range b.orders {
+		if o.Active() && !onBook[id] {
+			o.Status = StatusGone
+		}
+	}
+}
+
+// Apply records a fill, returning the order it belongs to. A fill for an
+// order we don't know yet is held for Place and nil is returned.
+func (b *Book) Apply(f Fill) *Order {
+	o := b.orders[f.OrderId]
+	if o == nil {
+		b.early[f.OrderId] = append(b.early[f.OrderId], f)
+		if f.Side != "" {
+			b.record(f)
+		}
+		return nil
+	}
+
+	if f.Side == "" {
+		f.Side = o.Side
+	}
+	b.fill(o, f.Amount)
+	b.record(f)
+	return o
+}
+
+// fill adds amount to o's filled size
+func (b *Book) fill(o *Order, amount int) {
+	o.Filled += amount
+	if o.Filled >= o.Amount {
+		o.Filled = o.Amount
+		o.Status = StatusFilled
+	} else {
+		o.Status = StatusPartial
+	}
+}
+
+// Load replays persisted fills into the P&L without touching orders
+func (b *Book) Load(fills []Fill) {
+	for _, f := range fills {
+		b.record(f)
+	}
+}
+
+func (b *Book) record(f Fill) {
+	b.fills = append(b.fills, f)
+
+	s := &b.stats
+	switch f.Side {
+	case "bid":
+		cost := s.AvgCost*float64(s.Position) + f.Price*float64(f.Amount)
+		s.Position += f.Amount
+		s.AvgCost = cost / float64(s.Position)
+		s.Bought += f.Amount
+	case "ask":
+		fromPosition := min(f.Amount, s.Position)
+		s.Realized += (f.Price-s.AvgCost)*float64(fromPosition) + f.Price*float64(f.Amount-fromPosition)
+		s.Position -= fromPosition
+		if s.Position == 0 {
+			s.AvgCost = 0
+		}
+		s.Sold += f.Amount
+	}
+}
+
+// Orders returns our orders, newest first
+func (b *Book) Orders() []Order {
+	out := make([]Order, 0, len(b.orders))
+	for _, o := range b.orders {
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].PlacedAt.Equal(out[j].PlacedAt) {
+			return out[i].PlacedAt.After(out[j].PlacedAt)
+		}
+		return out[i].Id > out[j].Id
+	})
+	return out
+}
+
+// Open returns our active orders, newest first
+func (b *Book) Open() []Order {
+	var out []Order
+	for _, o := range b.Orders() {
+		if o.Active() {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Owns reports whether id is one of our orders
+func (b *Book) Owns(id string) bool {
+	_, ok := b.orders[id]
+	return ok
+}
+
+// Fills returns every fill recorded, oldest first
+func (b *Book) Fills() []Fill {
+	return b.fills
+}
+
+// Stats returns the running P&L
+func (b *Book) Stats() Stats {
+	return b.stats
+}
@@ -0,0 +1,213 @@
+package trades
+
+import (
+	"math"
+	"testing"
+
+	"github.com/philip/foam/internal/api"
+)
+
+// resting is one of al's orders on the book with amount remaining
+func resting(id, side string, amount int) api.MarketOrder {
+	return api.MarketOrder{Id: id, Player: "al", Side: side, Price: 1, Amount: amount}
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name       string
+		placed     []Order
+		snapshots  [][]api.MarketOrder // Bids, synced in order
+		id         string
+		wantStatus string
+		wantFilled int
+	}{
+		{
+			name:       "resting order stays open",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10}},
+			snapshots:  [][]api.MarketOrder{{resting("o1", "bid", 10)}},
+			id:         "o1",
+			wantStatus: StatusOpen,
+		},
+		{
+			name:       "missing order is gone",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10}},
+			snapshots:  [][]api.MarketOrder{{}},
+			id:         "o1",
+			wantStatus: StatusGone,
+		},
+		{
+			// A snapshot requested before the place landed, then a fresh one
+			name:       "stale snapshot then fresh revives",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10}},
+			snapshots:  [][]api.MarketOrder{{}, {resting("o1", "bid", 10)}},
+			id:         "o1",
+			wantStatus: StatusOpen,
+		},
+		{
+			name:       "revived with fills seen on the book",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10}},
+			snapshots:  [][]api.MarketOrder{{}, {resting("o1", "bid", 4)}},
+			id:         "o1",
+			wantStatus: StatusPartial,
+			wantFilled: 6,
+		},
+		{
+			name:       "smaller remaining is a partial fill",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10}},
+			snapshots:  [][]api.MarketOrder{{resting("o1", "bid", 7)}},
+			id:         "o1",
+			wantStatus: StatusPartial,
+			wantFilled: 3,
+		},
+		{
+			name:       "cancelled order is not revived",
+			placed:     []Order{{Id: "o1", Side: "bid", Amount: 10, Status: StatusCancelled}},
+			snapshots:  [][]api.MarketOrder{{resting("o1", "bid", 7)}},
+			id:         "o1",
+			wantStatus: StatusCancelled,
+			wantFilled: 3,
+		},
+		{
+			name:       "unknown resting order is adopted",
+			snapshots:  [][]api.MarketOrder{{resting("o2", "bid", 5)}},
+			id:         "o2",
+			wantStatus: StatusOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBook()
+			for _, o := range tt.placed {
+				b.Place(o)
+			}
+			for _, bids := range tt.snapshots {
+				b.Sync("al", bids, nil)
+			}
+			o := b.orders[tt.id]
+			if o == nil {
+				t.Fatalf("%s not in the book", tt.id)
+			}
+			if o.Status != tt.wantStatus || o.Filled != tt.wantFilled {
+				t.Errorf("status %s filled %d, want %s filled %d", o.Status, o.Filled, tt.wantStatus, tt.wantFilled)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		fills      []int
+		wantStatus string
+		wantFilled int
+	}{
+		{"partial", []int{4}, StatusPartial, 4},
+		{"two partials", []int{4, 3}, StatusPartial, 7},
+		{"complete", []int{4, 6}, StatusFilled, 10},
+		{"overfill is capped", []int{8, 8}, StatusFilled, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBook()
+			b.Place(Order{Id: "o1", Side: "bid", Price: 1, Amount: 10})
+			for _, n := range tt.fills {
+				b.Apply(Fill{OrderId: "o1", Amount: n, Price: 1})
+			}
+			o := b.orders["o1"]
+			if o.Status != tt.wantStatus || o.Filled != tt.wantFilled {
+				t.Errorf("status %s filled %d, want %s filled %d", o.Status, o.Filled, tt.wantStatus, tt.wantFilled)
+			}
+		})
+	}
+}
+
+func TestFillBeforePlace(t *testing.T) {
+	tests := []struct {
+		name       string
+		fills      []Fill
+		bids       []api.MarketOrder // Synced after the place
+		wantStatus string
+		wantFilled int
+		wantBought int
+	}{
+		{
+			name:       "filled at once stays filled",
+			fills:      []Fill{{OrderId: "o1", Side: "bid", Amount: 10, Price: 1}},
+			bids:       []api.MarketOrder{},
+			wantStatus: StatusFilled,
+			wantFilled: 10,
+			wantBought: 10,
+		},
+		{
+			name:       "rest of a partial fill rests",
+			fills:      []Fill{{OrderId: "o1", Side: "bid", Amount: 4, Price: 1}},
+			bids:       []api.MarketOrder{resting("o1", "bid", 6)},
+			wantStatus: StatusPartial,
+			wantFilled: 4,
+			wantBought: 4,
+		},
+		{
+			name:       "side taken from the order",
+			fills:      []Fill{{OrderId: "o1", Amount: 3, Price: 1}},
+			bids:       []api.MarketOrder{resting("o1", "bid", 7)},
+			wantStatus: StatusPartial,
+			wantFilled: 3,
+			wantBought: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBook()
+			for _, f := range tt.fills {
+				if o := b.Apply(f); o != nil {
+					t.Fatalf("fill for an unplaced order matched %+v", o)
+				}
+			}
+			b.Place(Order{Id: "o1", Side: "bid", Price: 1, Amount: 10})
+			b.Sync("al", tt.bids, nil)
+
+			o := b.orders["o1"]
+			if o.Status != tt.wantStatus || o.Filled != tt.wantFilled {
+				t.Errorf("status %s filled %d, want %s filled %d", o.Status, o.Filled, tt.wantStatus, tt.wantFilled)
+			}
+			if got := b.Stats().Bought; got != tt.wantBought {
+				t.Errorf("bought %d, want %d", got, tt.wantBought)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		fills []Fill
+		want  Stats
+	}{
+		{
+			name:  "buys average their cost",
+			fills: []Fill{{Side: "bid", Amount: 10, Price: 1}, {Side: "bid", Amount: 10, Price: 2}},
+			want:  Stats{Bought: 20, Position: 20, AvgCost: 1.5},
+		},
+		{
+			name:  "sale realizes against the average",
+			fills: []Fill{{Side: "bid", Amount: 10, Price: 1}, {Side: "ask", Amount: 4, Price: 1.5}},
+			want:  Stats{Bought: 10, Sold: 4, Position: 6, AvgCost: 1, Realized: 2},
+		},
+		{
+			name:  "selling produced nits is all profit",
+			fills: []Fill{{Side: "bid", Amount: 2, Price: 1}, {Side: "ask", Amount: 5, Price: 2}},
+			want:  Stats{Bought: 2, Sold: 5, Position: 0, AvgCost: 0, Realized: 2 + 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBook()
+			b.Load(tt.fills)
+			got := b.Stats()
+			if got.Bought != tt.want.Bought || got.Sold != tt.want.Sold || got.Position != tt.want.Position ||
+				math.Abs(got.AvgCost-tt.want.AvgCost) > 1e-9 || math.Abs(got.Realized-tt.want.Realized) > 1e-9 {
+				t.Errorf("stats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/alert"
+	"github.com/philip/foam/internal/eventlog"
+)
+
+// alertTimeout bounds a slow shell hook
+const alertTimeout = 10 * time.Second
+
+// alertMsg reports alerts that failed to deliver
+type alertMsg struct {
+	err error
+}
+
+// SetAlerts enables notifications for events matching n's rules. Bell
+// and notification escapes are written to out, the program's terminal.
+func (a *App) SetAlerts(n *alert.Notifier, out io.Writer) {
+	a.alerts = n
+	a.alertOut = out
+}
+
+// raise queues an alert for delivery once the current event is handled
+func (a *App) raise(trigger string, amount int, format string, args ...any) {
+	if a.alerts == nil {
+		return
+	}
+	al := alert.Alert{
+		Trigger: trigger,
+		Title:   "foam",
+		Message: fmt.Sprintf(format, args...),
+		Amount:  amount,
+	}
+	if len(a.alerts.Match(al)) > 0 {
+		a.pendingAlerts = append(a.pendingAlerts, al)
+	}
+}
+
+// fireAlerts delivers queued alerts off the UI goroutine, writing their
+// escapes once, in one write: the renderer also writes each frame in one
+// call on the same file, so the two never interleave
+func (a *App) fireAlerts() tea.Cmd {
+	pending, n, out := a.pendingAlerts, a.alerts, a.alertOut
+	a.pendingAlerts = nil
+
+	var esc strings.Builder
+	for _, al := range pending {
+		esc.WriteString(n.Escapes(al))
+	}
+	return func() tea.Msg {
+		var errs []error
+		if esc.Len() > 0 && out != nil {
+			if _, err := io.WriteString(out, esc.String()); err != nil {
+				errs = append(errs, fmt.Errorf("write alert: %w", err))
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+		for _, al := range pending {
+			if err := n.Fire(ctx, al); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return alertMsg{err}
+		}
+		return nil
+	}
+}
+
+func (a *App) handleAlertResult(msg alertMsg) {
+	a.logEvent(eventlog.Error, eventlog.KindAction, "Alert failed: %v", msg.err)
+}
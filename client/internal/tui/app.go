@@ -1,15 +1,25 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/alert"
 	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/eventlog"
+	"github.com/philip/foam/internal/logging"
+	"github.com/philip/foam/internal/market"
+	"github.com/philip/foam/internal/rules"
+	"github.com/philip/foam/internal/trades"
 )
 
 // View modes
@@ -20,6 +30,8 @@ const (
 	viewRoutes
 	viewPOIs
 	viewMarket
+	viewMap
+	viewLog
 )
 
 // Connection states
@@ -28,12 +40,28 @@ type connState int
 const (
 	stateConnecting connState = iota
 	stateConnected
+	stateReconnecting
 	stateDisconnected
+	stateAuth // The server wants a (fresh) session token
 )
 
+// linkStaleAfter is how long without any frame before the link is flagged;
+// the server ticks every 10s so two missed ticks is suspicious
+const linkStaleAfter = 20 * time.Second
+
 // Messages
-type serverMsg api.ServerMessage
+type serverMsg struct{ api.Event }
 type errMsg error
+type reconnectMsg api.ReconnectEvent
+
+// sendResultMsg reports the server's answer to an outbound action
+type sendResultMsg struct {
+	action  string // What we tried, for the failure message
+	confirm string // Status shown on success
+	ack     api.AckEvent
+	err     error
+	onAck   func(*App, api.AckEvent) tea.Cmd // Runs on the UI goroutine after success
+}
 
 // connectMsg signals successful connection
 type connectMsg struct{}
@@ -41,33 +69,78 @@ type connectMsg struct{}
 // App is the main TUI model
 type App struct {
 	// Connection
-	client    *api.Client
-	connState connState
-	serverURL string
-	username  string
+	client           *api.Client
+	connState        connState
+	serverURL        string
+	username         string
+	reconnectAttempt int           // Current redial attempt while reconnecting
+	recorder         *api.Recorder // Records the session for foam replay
+	replay           *replayState  // Set when playing a recording instead
+	logger           *slog.Logger  // Diagnostics for the app and its client
+	logRing          *logging.Ring // Recent diagnostics for the log pane
+
+	// Credentials for a claimed username
+	session     *api.Session
+	saveSession func(api.Session) error
+	authCode    string // Why stateAuth was entered
+	authBusy    bool   // A claim or refresh is in flight
 
 	// Player state
 	player *api.PlayerState
+	rules  rules.Params // Economy the previews and forecasts assume
 
 	// Game state
 	routes           []api.RouteState
-	pendingRequests  []struct{ from, routeId string }
+	pendingRequests  []routeRequest
+	outboundRequests []outboundRequest
 	intersections    []api.IntersectionState
 	marketBids       []api.MarketOrder
 	marketAsks       []api.MarketOrder
-	controlledPois   []string // POI IDs we control
-	tollsReceived    int      // Total tolls received this session
+	marketStale      bool         // Refetch the book after this update
+	book             *trades.Book // Our orders, fills and P&L
+	fillsPath        string       // Fill history, appended as fills arrive
+	orderCursor      int          // Selected open order in the market view
+	amending         trades.Order // Order being replaced by the "amend" input
+	prices           market.Series
+	mids             market.Series // Book mids, drawn where no trade is
+	chartWindow      int           // Index into chartWindows
+	controlledPois   []string      // POI IDs we control
+	tollsReceived    int           // Total tolls received this session
+
+	// Fog of war: who we can see, and who recently came or went
+	visiblePlayers    map[string]api.VisiblePlayer
+	visibilityChanges map[string]visibilityChange
+	visibilityKnown   bool // First update seen; it doesn't count as arrivals
+
+	// Event log, newest last
+	events         []eventlog.Entry
+	eventsPath     string // Event history, appended as events arrive
+	unread         int    // Events since the log was last opened
+	unreadSeverity eventlog.Severity
+	logScroll      int // Entries scrolled up from the newest
+	logMinSeverity eventlog.Severity
+	logFilter      string
+
+	// Notifications for events that need attention
+	alerts        *alert.Notifier
+	pendingAlerts []alert.Alert // Raised by the event being handled
+	alertOut      io.Writer     // The terminal, for the bell and notification escapes
 
 	// UI state
-	viewMode    viewMode
-	spinner     spinner.Model
-	input       textinput.Model
-	inputMode   string // "", "route", "bid", "ask", "invest"
-	selectedPoi int    // For POI view navigation
-	width       int
-	height      int
-	err         error
-	statusMsg   string
+	viewMode     viewMode
+	spinner      spinner.Model
+	input        textinput.Model
+	inputMode    string // "", "route", "bid", "ask", "amend", "invest", "filter"
+	selectedPoi  int    // For POI view navigation
+	routeCursor  int    // Inbound requests, outbound requests, then routes
+	upgradeArmed string // Route id awaiting a second u to confirm its upgrade
+	mapView      mapState
+	showDebug    bool // Show client delivery counters
+	showLogPane  bool // Show recent client diagnostics
+	width        int
+	height       int
+	err          error
+	statusMsg    string
 }
 
 // NewApp creates a new App instance
@@ -87,11 +160,26 @@ func NewApp(serverURL, username string) *App {
 		spinner:   s,
 		input:     ti,
 		viewMode:  viewDashboard,
+		book:      trades.NewBook(),
+		rules:     rules.Current,
 	}
 }
 
+// SetRules picks the economy parameters for forecasts and previews
+func (a *App) SetRules(p rules.Params) {
+	a.rules = p
+}
+
+// SetRecorder records the session's frames and market snapshots
+func (a *App) SetRecorder(r *api.Recorder) {
+	a.recorder = r
+}
+
 // Init initializes the app
 func (a *App) Init() tea.Cmd {
+	if a.replay != nil {
+		return tea.Batch(a.spinner.Tick, a.scheduleReplay())
+	}
 	return tea.Batch(
 		a.spinner.Tick,
 		a.connect(),
@@ -102,6 +190,11 @@ func (a *App) Init() tea.Cmd {
 func (a *App) connect() tea.Cmd {
 	return func() tea.Msg {
 		a.client = api.NewClient(a.serverURL, a.username)
+		a.client.Recorder = a.recorder
+		a.client.Logger = a.logger
+		if a.session != nil {
+			a.client.SetToken(a.session.Token)
+		}
 		if err := a.client.Connect(); err != nil {
 			return errMsg(err)
 		}
@@ -114,15 +207,26 @@ func (a *App) listenForMessages() tea.Cmd {
 	return func() tea.Msg {
 		select {
 		case msg := <-a.client.Messages:
-			return serverMsg(msg)
+			return serverMsg{msg}
 		case err := <-a.client.Errors:
 			return errMsg(err)
+		case ev := <-a.client.Reconnects:
+			return reconnectMsg(ev)
 		case <-a.client.Done:
 			return nil
 		}
 	}
 }
 
+// send runs an outbound request off the UI goroutine and reports the
+// server's matching ack or error
+func (a *App) send(action, confirm string, fn func(ctx context.Context) (api.AckEvent, error)) tea.Cmd {
+	return func() tea.Msg {
+		ack, err := fn(context.Background())
+		return sendResultMsg{action: action, confirm: confirm, ack: ack, err: err}
+	}
+}
+
 // Update handles messages
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -132,6 +236,8 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
+		a.clampLogScroll()
+		a.refitMap()
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -139,15 +245,59 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, cmd
 
 	case connectMsg:
-		return a, a.listenForMessages()
+		a.log().Info("connected", "server", a.serverURL, "user", a.username)
+		// Prime the price ticker
+		return a, tea.Batch(a.listenForMessages(), a.refreshMarket())
 
 	case errMsg:
 		a.err = msg
 		a.connState = stateDisconnected
+		a.log().Error("disconnected", "err", error(msg))
+		a.logEvent(eventlog.Error, eventlog.KindConnection, "Disconnected: %v", error(msg))
+		return a, nil
+
+	case reconnectMsg:
+		// The client keeps redialing on its own; fresh connected/state
+		// messages after the re-auth bring us back to stateConnected
+		if a.connState != stateReconnecting {
+			a.logEvent(eventlog.Warn, eventlog.KindConnection, "Connection lost, reconnecting: %v", msg.Err)
+		}
+		a.connState = stateReconnecting
+		a.reconnectAttempt = msg.Attempt
+		a.log().Debug("reconnect attempt", "attempt", msg.Attempt, "delay", msg.Delay)
+		a.err = msg.Err
+		return a, a.listenForMessages()
+
+	case sendResultMsg:
+		if msg.err != nil {
+			a.log().Warn("action failed", "action", msg.action, "err", msg.err)
+			a.logEvent(eventlog.Error, eventlog.KindAction, "Failed to %s: %v", msg.action, msg.err)
+			return a, nil
+		}
+		if msg.confirm != "" {
+			a.logEvent(eventlog.Info, eventlog.KindAction, "%s", msg.confirm)
+		}
+		if msg.onAck != nil {
+			return a, msg.onAck(a, msg.ack)
+		}
+		return a, nil
+
+	case marketMsg:
+		a.handleMarket(msg)
 		return a, nil
 
+	case authMsg:
+		return a, a.handleAuthResult(msg)
+
+	case alertMsg:
+		a.handleAlertResult(msg)
+		return a, nil
+
+	case replayMsg:
+		return a, a.handleReplayMsg(msg)
+
 	case serverMsg:
-		return a.handleServerMessage(api.ServerMessage(msg))
+		return a, tea.Batch(a.handleEvent(msg.Event), a.listenForMessages())
 	}
 
 	// Update text input
@@ -160,6 +310,22 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, nil
 }
 
+// handleEvent applies a server event and returns the follow-up work it
+// asked for
+func (a *App) handleEvent(ev api.Event) tea.Cmd {
+	a.log().Debug("handling event", "type", ev.Type())
+	ev.Dispatch(a)
+	var cmds []tea.Cmd
+	if a.marketStale {
+		a.marketStale = false
+		cmds = append(cmds, a.refreshMarket())
+	}
+	if len(a.pendingAlerts) > 0 {
+		cmds = append(cmds, a.fireAlerts())
+	}
+	return tea.Batch(cmds...)
+}
+
 func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
 
@@ -183,6 +349,23 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return a, cmd
 	}
 
+	if a.connState == stateAuth && key == "enter" && !a.authBusy {
+		a.authBusy = true
+		return a, a.renewSession()
+	}
+
+	if a.viewMode == viewMap && a.handleMapKey(key) {
+		return a, nil
+	}
+	if a.viewMode == viewLog && a.handleLogKey(key) {
+		return a, nil
+	}
+	if a.replay != nil {
+		if cmd, ok := a.handleReplayKey(key); ok {
+			return a, cmd
+		}
+	}
+
 	// Normal mode
 	switch key {
 	case "q", "ctrl+c":
@@ -191,6 +374,11 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return a, tea.Quit
 
+	case "d":
+		a.showDebug = !a.showDebug
+	case "D":
+		a.toggleLogPane()
+
 	case "1":
 		a.viewMode = viewDashboard
 	case "2":
@@ -199,6 +387,13 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		a.viewMode = viewPOIs
 	case "4":
 		a.viewMode = viewMarket
+		return a, a.refreshMarket()
+	case "5":
+		a.viewMode = viewMap
+		a.refitMap()
+	case "6":
+		a.viewMode = viewLog
+		a.markEventsRead()
 
 	case "r":
 		if a.viewMode == viewRoutes || a.viewMode == viewDashboard {
@@ -208,14 +403,18 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case "a":
-		// Accept first pending route request
-		if len(a.pendingRequests) > 0 {
-			req := a.pendingRequests[0]
-			a.pendingRequests = a.pendingRequests[1:]
-			return a, func() tea.Msg {
-				a.client.AcceptRoute(req.routeId)
-				return nil
+		// Accept the selected request, or the oldest one outside the routes view
+		if a.viewMode == viewRoutes {
+			if req, ok := a.selectedRequest(); ok {
+				return a, a.answerRequest(req, true)
 			}
+		} else if len(a.pendingRequests) > 0 {
+			return a, a.answerRequest(a.pendingRequests[0], true)
+		}
+
+	case "x":
+		if req, ok := a.selectedRequest(); ok && a.viewMode == viewRoutes {
+			return a, a.answerRequest(req, false)
 		}
 
 	case "b":
@@ -243,20 +442,58 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if a.viewMode == viewPOIs && len(a.intersections) > 0 {
 			a.selectedPoi = (a.selectedPoi + 1) % len(a.intersections)
 		}
+		if a.viewMode == viewMarket {
+			a.moveOrderCursor(1)
+		}
+		if a.viewMode == viewRoutes {
+			a.moveRouteCursor(1)
+		}
 
 	case "k", "up":
 		if a.viewMode == viewPOIs && len(a.intersections) > 0 {
 			a.selectedPoi = (a.selectedPoi - 1 + len(a.intersections)) % len(a.intersections)
 		}
+		if a.viewMode == viewMarket {
+			a.moveOrderCursor(-1)
+		}
+		if a.viewMode == viewRoutes {
+			a.moveRouteCursor(-1)
+		}
+
+	case "c":
+		if o, ok := a.selectedOrder(); ok && a.viewMode == viewMarket {
+			a.statusMsg = fmt.Sprintf("Cancelling %s %d @ %.2f...", o.Side, o.Remaining(), o.Price)
+			return a, a.cancelOrder(o)
+		}
+
+	case "e":
+		if o, ok := a.selectedOrder(); ok && a.viewMode == viewMarket {
+			a.amending = o
+			a.inputMode = "amend"
+			a.input.Placeholder = "Enter new price amount..."
+			a.input.SetValue(fmt.Sprintf("%.2f %d", o.Price, o.Remaining()))
+			a.input.CursorEnd()
+			a.input.Focus()
+		}
+
+	case "w":
+		if a.viewMode == viewMarket {
+			a.chartWindow = (a.chartWindow + 1) % len(chartWindows)
+		}
+		if req, ok := a.selectedOutbound(); ok && a.viewMode == viewRoutes && req.status == outboundAwaiting {
+			return a, a.withdrawRoute(*req)
+		}
+
+	case "X":
+		if a.viewMode == viewMarket && len(a.book.Open()) > 0 {
+			a.statusMsg = fmt.Sprintf("Cancelling all %d open orders...", len(a.book.Open()))
+			return a, a.cancelAllOrders()
+		}
 
 	case "u":
-		// Upgrade selected route
-		if a.viewMode == viewRoutes && len(a.routes) > 0 {
-			route := a.routes[0] // TODO: add route selection
-			return a, func() tea.Msg {
-				a.client.UpgradeRoute(route.Id)
-				return nil
-			}
+		// Upgrade the selected route
+		if route, ok := a.selectedRoute(); ok && a.viewMode == viewRoutes {
+			return a, a.upgradeRoute(*route)
 		}
 	}
 
@@ -268,10 +505,7 @@ func (a *App) handleInputSubmit(mode, value string) tea.Cmd {
 	case "route":
 		if value != "" {
 			a.statusMsg = fmt.Sprintf("Requesting route to %s...", value)
-			return func() tea.Msg {
-				a.client.RequestRoute(value)
-				return nil
-			}
+			return a.requestRoute(value)
 		}
 	case "bid", "ask":
 		var price float64
@@ -280,124 +514,37 @@ func (a *App) handleInputSubmit(mode, value string) tea.Cmd {
 		if err == nil && price > 0 && amount > 0 {
 			side := mode
 			a.statusMsg = fmt.Sprintf("Placing %s order: %d nits @ %.2f", side, amount, price)
-			return func() tea.Msg {
-				a.client.PlaceOrder(side, price, amount)
-				return nil
-			}
+			return a.placeOrder(side, price, amount)
+		}
+	case "amend":
+		var price float64
+		var amount int
+		_, err := fmt.Sscanf(value, "%f %d", &price, &amount)
+		if err == nil && price > 0 && amount > 0 {
+			o := a.amending
+			a.statusMsg = fmt.Sprintf("Amending %s order to %d nits @ %.2f...", o.Side, amount, price)
+			return a.amendOrder(o, price, amount)
 		}
+	case "filter":
+		a.logFilter = value
+		a.logScroll = 0
 	case "invest":
 		var amount int
 		_, err := fmt.Sscanf(value, "%d", &amount)
 		if err == nil && amount > 0 && len(a.intersections) > 0 {
 			poi := a.intersections[a.selectedPoi]
-			a.statusMsg = fmt.Sprintf("Investing %d nits in POI...", amount)
-			return func() tea.Msg {
-				a.client.InvestPoi(poi.Id, amount)
-				return nil
-			}
+			where := formatCoords(poi.Coordinates.Lat, poi.Coordinates.Lng)
+			a.statusMsg = fmt.Sprintf("Investing %d nits in POI %s...", amount, where)
+			return a.send("invest in POI "+where,
+				fmt.Sprintf("Investment confirmed: %d nits in POI %s", amount, where),
+				func(ctx context.Context) (api.AckEvent, error) {
+					return a.client.InvestPoiContext(ctx, poi.Id, amount)
+				})
 		}
 	}
 	return nil
 }
 
-// handleServerMessage processes messages from the server
-func (a *App) handleServerMessage(msg api.ServerMessage) (tea.Model, tea.Cmd) {
-	switch msg.Type {
-	case "connected":
-		a.connState = stateConnected
-		return a, a.listenForMessages()
-
-	case "state":
-		if msg.Player != nil {
-			a.player = msg.Player
-		}
-		return a, a.listenForMessages()
-
-	case "tick":
-		if a.player != nil {
-			a.player.Nits = msg.Nits
-			a.player.Heat = msg.Heat
-		}
-		return a, a.listenForMessages()
-
-	case "heat_update":
-		if a.player != nil {
-			a.player.Heat = msg.Heat
-		}
-		return a, a.listenForMessages()
-
-	case "error":
-		a.statusMsg = "Error: " + msg.Message
-		return a, a.listenForMessages()
-
-	case "route_request":
-		a.pendingRequests = append(a.pendingRequests, struct{ from, routeId string }{msg.From, msg.RouteId})
-		a.statusMsg = fmt.Sprintf("Route request from %s!", msg.From)
-		return a, a.listenForMessages()
-
-	case "route_accepted":
-		if msg.Route != nil {
-			a.routes = append(a.routes, *msg.Route)
-			a.statusMsg = fmt.Sprintf("Route established: %s ↔ %s", msg.Route.PlayerA, msg.Route.PlayerB)
-		}
-		return a, a.listenForMessages()
-
-	case "routes":
-		a.routes = msg.Routes
-		return a, a.listenForMessages()
-
-	case "intersection_created":
-		if msg.Intersection != nil {
-			a.intersections = append(a.intersections, *msg.Intersection)
-			a.statusMsg = "New POI created!"
-		}
-		return a, a.listenForMessages()
-
-	case "poi_update":
-		if msg.Poi != nil {
-			// Update existing POI or add new one
-			found := false
-			for i, poi := range a.intersections {
-				if poi.Id == msg.Poi.Id {
-					a.intersections[i] = *msg.Poi
-					found = true
-					break
-				}
-			}
-			if !found {
-				a.intersections = append(a.intersections, *msg.Poi)
-			}
-
-			// Check if we control this POI
-			if a.player != nil && msg.Poi.Controller == a.player.Username {
-				if !contains(a.controlledPois, msg.Poi.Id) {
-					a.controlledPois = append(a.controlledPois, msg.Poi.Id)
-					a.statusMsg = "You now control a POI!"
-				}
-			} else {
-				a.controlledPois = remove(a.controlledPois, msg.Poi.Id)
-			}
-		}
-		return a, a.listenForMessages()
-
-	case "poi_contest":
-		a.statusMsg = fmt.Sprintf("POI contested by %s!", msg.Attacker)
-		return a, a.listenForMessages()
-
-	case "toll_received":
-		a.tollsReceived += msg.Amount
-		a.statusMsg = fmt.Sprintf("Received %d nits in tolls!", msg.Amount)
-		return a, a.listenForMessages()
-
-	case "market_update":
-		a.marketBids = msg.Bids
-		a.marketAsks = msg.Asks
-		return a, a.listenForMessages()
-	}
-
-	return a, a.listenForMessages()
-}
-
 // View renders the UI
 func (a *App) View() string {
 	var content string
@@ -407,8 +554,12 @@ func (a *App) View() string {
 		content = a.renderConnecting()
 	case stateConnected:
 		content = a.renderConnected()
+	case stateReconnecting:
+		content = a.renderReconnecting()
 	case stateDisconnected:
 		content = a.renderDisconnected()
+	case stateAuth:
+		content = a.renderAuth()
 	}
 
 	return content
@@ -420,6 +571,19 @@ func (a *App) renderConnecting() string {
 	)
 }
 
+func (a *App) renderReconnecting() string {
+	errStr := ""
+	if a.err != nil {
+		errStr = "\n  " + DimStyle.Render(a.err.Error())
+	}
+	return ContainerStyle.Render(
+		fmt.Sprintf("%s %s", a.spinner.View(),
+			WarningStyle.Render(fmt.Sprintf("reconnecting (attempt %d)", a.reconnectAttempt))) +
+			errStr + "\n\n" +
+			HelpStyle.Render("q: quit"),
+	)
+}
+
 func (a *App) renderDisconnected() string {
 	errStr := ""
 	if a.err != nil {
@@ -448,6 +612,19 @@ func (a *App) renderConnected() string {
 		b.WriteString(a.renderPOIsView())
 	case viewMarket:
 		b.WriteString(a.renderMarketView())
+	case viewMap:
+		b.WriteString(a.renderMapView())
+	case viewLog:
+		b.WriteString(a.renderLogView())
+	}
+
+	if a.showDebug {
+		b.WriteString("\n\n")
+		b.WriteString(a.renderDebug())
+	}
+	if a.showLogPane {
+		b.WriteString("\n\n")
+		b.WriteString(a.renderLogPane())
 	}
 
 	// Status line
@@ -460,6 +637,10 @@ func (a *App) renderConnected() string {
 	if a.inputMode != "" {
 		b.WriteString("\n")
 		b.WriteString(a.input.View())
+		if preview := a.renderActionPreview(); preview != "" {
+			b.WriteString("\n")
+			b.WriteString(preview)
+		}
 	}
 
 	// Help
@@ -470,22 +651,57 @@ func (a *App) renderConnected() string {
 }
 
 func (a *App) renderHeader() string {
-	tabs := []string{"[1]Dashboard", "[2]Routes", "[3]POIs", "[4]Market"}
+	tabs := []string{"[1]Dashboard", "[2]Routes", "[3]POIs", "[4]Market", "[5]Map", "[6]Log"}
 	active := int(a.viewMode)
 
 	var rendered []string
 	for i, tab := range tabs {
 		if i == active {
-			rendered = append(rendered, TabActiveStyle.Render(tab))
+			tab = TabActiveStyle.Render(tab)
 		} else {
-			rendered = append(rendered, TabStyle.Render(tab))
+			tab = TabStyle.Render(tab)
+		}
+		if viewMode(i) == viewLog {
+			tab += a.unreadBadge()
 		}
+		rendered = append(rendered, tab)
 	}
 
 	title := HeaderStyle.Render("foam")
 	tabBar := strings.Join(rendered, " ")
 
-	return lipgloss.JoinHorizontal(lipgloss.Top, title, "  ", tabBar)
+	header := lipgloss.JoinHorizontal(lipgloss.Top, title, "  ", tabBar, "  ", a.renderLink())
+	if ticker := a.renderTicker(); ticker != "" {
+		header = lipgloss.JoinHorizontal(lipgloss.Top, header, "  ", ticker)
+	}
+	return header
+}
+
+// renderLink shows ping round-trip time, or how long the link has been silent
+func (a *App) renderLink() string {
+	if a.replay != nil {
+		return a.renderReplay()
+	}
+	if a.client == nil {
+		return ""
+	}
+
+	lastSeen := a.client.LastSeen()
+	if since := time.Since(lastSeen); !lastSeen.IsZero() && since > linkStaleAfter {
+		return WarningStyle.Render(fmt.Sprintf("◌ stale %ds", int(since.Seconds())))
+	}
+
+	rtt := a.client.Latency()
+	switch {
+	case rtt == 0:
+		return DimStyle.Render("● --ms")
+	case rtt > time.Second:
+		return DisconnectedStyle.Render(fmt.Sprintf("● %dms", rtt.Milliseconds()))
+	case rtt > 250*time.Millisecond:
+		return WarningStyle.Render(fmt.Sprintf("● %dms", rtt.Milliseconds()))
+	default:
+		return ConnectedStyle.Render(fmt.Sprintf("● %dms", rtt.Milliseconds()))
+	}
 }
 
 func (a *App) renderDashboard() string {
@@ -506,9 +722,7 @@ func (a *App) renderDashboard() string {
 		location = formatCoords(a.player.Coordinates.Lat, a.player.Coordinates.Lng)
 	}
 
-	// Calculate production bonus
-	poiBonus := float64(len(a.controlledPois)) * 0.5
-	totalProd := float64(a.player.ProductionRate) + poiBonus
+	totalProd := a.rules.Production(a.player.ProductionRate, len(a.controlledPois))
 
 	playerBox := BoxStyle.Render(
 		lipgloss.JoinVertical(lipgloss.Left,
@@ -554,40 +768,8 @@ func (a *App) renderDashboard() string {
 	)
 
 	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, playerBox, "  ", routesBox, "  ", poisBox))
-
-	return b.String()
-}
-
-func (a *App) renderRoutesView() string {
-	var b strings.Builder
-
-	b.WriteString(LabelStyle.Render("ACTIVE ROUTES"))
-	b.WriteString("\n\n")
-
-	if len(a.routes) == 0 {
-		b.WriteString(DimStyle.Render("  No routes established"))
-		b.WriteString("\n")
-		b.WriteString(DimStyle.Render("  Press 'r' to request a route"))
-	} else {
-		for _, route := range a.routes {
-			status := ConnectedStyle.Render("●")
-			if route.Status != "active" {
-				status = WarningStyle.Render("○")
-			}
-			b.WriteString(fmt.Sprintf("  %s %s ↔ %s (cap: %d)\n",
-				status, route.PlayerA, route.PlayerB, route.Capacity))
-		}
-	}
-
-	if len(a.pendingRequests) > 0 {
-		b.WriteString("\n")
-		b.WriteString(LabelStyle.Render("PENDING REQUESTS"))
-		b.WriteString("\n\n")
-		for _, req := range a.pendingRequests {
-			b.WriteString(fmt.Sprintf("  %s from %s [a]ccept\n",
-				WarningStyle.Render("?"), req.from))
-		}
-	}
+	b.WriteString("\n")
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, a.renderHeatPanel(), "  ", a.renderVisiblePanel()))
 
 	return b.String()
 }
@@ -664,42 +846,68 @@ func (a *App) renderMarketView() string {
 	b.WriteString(LabelStyle.Render("MARKET"))
 	b.WriteString("\n\n")
 
-	// Bids (buy orders)
-	b.WriteString("  BIDS (buy)\n")
-	if len(a.marketBids) == 0 {
-		b.WriteString(DimStyle.Render("    No bids\n"))
-	} else {
-		for _, bid := range a.marketBids {
-			b.WriteString(fmt.Sprintf("    %.2f × %d (%s)\n", bid.Price, bid.Amount, bid.Player))
-		}
+	chartWidth := 60
+	if a.width > 0 {
+		chartWidth = max(a.width-16, 20)
 	}
+	b.WriteString(a.renderPriceChart(chartWidth, 8))
+	b.WriteString("\n\n")
 
-	b.WriteString("\n")
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, a.renderLadder(), "    ", a.renderBlotter()))
 
-	// Asks (sell orders)
-	b.WriteString("  ASKS (sell)\n")
-	if len(a.marketAsks) == 0 {
-		b.WriteString(DimStyle.Render("    No asks\n"))
-	} else {
-		for _, ask := range a.marketAsks {
-			b.WriteString(fmt.Sprintf("    %.2f × %d (%s)\n", ask.Price, ask.Amount, ask.Player))
-		}
+	return b.String()
+}
+
+func (a *App) renderDebug() string {
+	if a.client == nil {
+		return ""
 	}
+	stats := a.client.Stats()
 
-	return b.String()
+	parseErrors := fmt.Sprintf("%d", stats.ParseErrors)
+	if stats.ParseErrors > 0 {
+		parseErrors = WarningStyle.Render(parseErrors)
+	}
+	dropped := fmt.Sprintf("%d", stats.Dropped)
+	if stats.Dropped > 0 {
+		dropped = DisconnectedStyle.Render(dropped)
+	}
+
+	lines := []string{
+		LabelStyle.Render("DEBUG"),
+		"",
+		fmt.Sprintf("  received   %d", stats.Received),
+		fmt.Sprintf("  delivered  %d", stats.Delivered),
+		fmt.Sprintf("  queued     %d (max %d)", stats.Queued, stats.MaxQueued),
+		fmt.Sprintf("  parse err  %s", parseErrors),
+		fmt.Sprintf("  unknown    %d", stats.UnknownTypes),
+		fmt.Sprintf("  dropped    %s", dropped),
+	}
+	if stats.LastParseError != "" {
+		lines = append(lines, "", "  "+DimStyle.Render(stats.LastParseError))
+	}
+
+	return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
 }
 
 func (a *App) renderHelp() string {
 	var help string
 	switch a.viewMode {
 	case viewDashboard:
-		help = "1-4: views | r: request route | q: quit"
+		help = "1-6: views | r: request route | d/D: debug/log | q: quit"
 	case viewRoutes:
-		help = "1-4: views | r: request route | a: accept | u: upgrade | q: quit"
+		help = "1-6: views | r: request route | j/k: select | a: accept | x: reject | w: withdraw | u: upgrade | d/D: debug/log | q: quit"
 	case viewPOIs:
-		help = "1-4: views | j/k: navigate | i: invest | q: quit"
+		help = "1-6: views | j/k: navigate | i: invest | d/D: debug/log | q: quit"
 	case viewMarket:
-		help = "1-4: views | b: bid | s: sell | q: quit"
+		help = "1-6: views | b: bid | s: sell | w: window | j/k: select | c: cancel | e: amend | X: cancel all | d/D: debug/log | q: quit"
+	case viewMap:
+		help = "1-6: views | hjkl/arrows: pan | +/-: zoom | 0: fit | d/D: debug/log | q: quit"
+	case viewLog:
+		help = "1-6: views | j/k: scroll | g/G: oldest/newest | f: severity | /: filter | esc: clear | d/D: debug/log | q: quit"
+	}
+	if a.replay != nil {
+		help += "\nreplay: space: pause | .: step | </>: speed"
 	}
 	return HelpStyle.Render(help)
 }
@@ -717,6 +925,15 @@ func formatCoords(lat, lng float64) string {
 }
 
 // Helper functions
+
+// me returns our username as the server spells it
+func (a *App) me() string {
+	if a.player != nil {
+		return a.player.Username
+	}
+	return a.username
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
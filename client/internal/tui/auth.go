@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/api"
+)
+
+// authMsg reports the outcome of a claim or refresh started from the prompt
+type authMsg struct {
+	session api.Session
+	err     error
+}
+
+// SetSession gives the app a claimed username's credentials; save is called
+// with each refreshed session so it survives restarts
+func (a *App) SetSession(s api.Session, save func(api.Session) error) {
+	a.session = &s
+	a.saveSession = save
+}
+
+// onAuthError switches to the auth prompt for the server's auth error codes,
+// reporting whether ev was one
+func (a *App) onAuthError(ev api.ErrorEvent) bool {
+	if ev.Code != api.CodeAuthRequired && ev.Code != api.CodeAuthExpired {
+		return false
+	}
+	a.log().Warn("authentication rejected", "code", ev.Code, "message", ev.Message)
+	a.connState = stateAuth
+	a.authCode = ev.Code
+	a.err = errors.New(ev.Message)
+	return true
+}
+
+// renewSession refreshes the stored session, or claims the username when
+// there is none yet
+func (a *App) renewSession() tea.Cmd {
+	serverURL, username, session := a.serverURL, a.username, a.session
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if session == nil {
+			s, err := api.Claim(ctx, serverURL, username)
+			return authMsg{session: s, err: err}
+		}
+		s, err := api.Refresh(ctx, serverURL, *session)
+		return authMsg{session: s, err: err}
+	}
+}
+
+// handleAuthResult stores a renewed session and re-authenticates with it
+func (a *App) handleAuthResult(msg authMsg) tea.Cmd {
+	a.authBusy = false
+	if msg.err != nil {
+		switch {
+		case errors.Is(msg.err, api.ErrInvalidRefresh):
+			a.err = fmt.Errorf("%w; delete the stored credentials to claim again", msg.err)
+		default:
+			a.err = msg.err
+		}
+		return nil
+	}
+
+	a.session = &msg.session
+	if a.saveSession != nil {
+		if err := a.saveSession(msg.session); err != nil {
+			a.statusMsg = fmt.Sprintf("Failed to save credentials: %v", err)
+		}
+	}
+
+	a.connState = stateConnecting
+	a.err = nil
+	client, token := a.client, msg.session.Token
+	return func() tea.Msg {
+		if err := client.Authenticate(token); err != nil {
+			return errMsg(err)
+		}
+		return nil
+	}
+}
+
+func (a *App) renderAuth() string {
+	title := "session expired"
+	action := "enter: refresh session"
+	if a.authCode == api.CodeAuthRequired {
+		title = fmt.Sprintf("%s is claimed; a valid token is required", a.username)
+		if a.session == nil {
+			action = "enter: try claiming " + a.username
+		}
+	}
+
+	status := ""
+	if a.authBusy {
+		status = "\n  " + a.spinner.View() + " contacting server..."
+	} else if a.err != nil {
+		status = "\n  " + DimStyle.Render(a.err.Error())
+	}
+
+	return ContainerStyle.Render(
+		WarningStyle.Render("⚿ "+title) + status + "\n\n" +
+			HelpStyle.Render(action+" • q: quit"),
+	)
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/logging"
+)
+
+// logPaneLines is how many records the log pane shows
+const logPaneLines = 10
+
+// SetLogger sends the app's and its client's diagnostics to l; ring, if
+// not nil, backs the log pane D toggles
+func (a *App) SetLogger(l *slog.Logger, ring *logging.Ring) {
+	a.logger = l
+	a.logRing = ring
+}
+
+// log returns the app's logger, or one that discards everything
+func (a *App) log() *slog.Logger {
+	if a.logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return a.logger
+}
+
+// renderLogPane shows the newest diagnostics, one line each
+func (a *App) renderLogPane() string {
+	if a.logRing == nil {
+		return ""
+	}
+	records := a.logRing.Records()
+	if len(records) > logPaneLines {
+		records = records[len(records)-logPaneLines:]
+	}
+
+	width := max(a.width-16, 40)
+	lines := []string{LabelStyle.Render("CLIENT LOG"), ""}
+	if len(records) == 0 {
+		lines = append(lines, DimStyle.Render("  Nothing logged yet"))
+	}
+	for _, r := range records {
+		text := r.Message
+		if r.Attrs != "" {
+			text += " " + r.Attrs
+		}
+		line := fmt.Sprintf("%s %-5s %s", r.Time.Format("15:04:05"), r.Level, text)
+		if len([]rune(line)) > width {
+			line = string([]rune(line)[:width-1]) + "…"
+		}
+		lines = append(lines, "  "+levelStyle(r.Level).Render(line))
+	}
+	return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// levelStyle colors a record by its level
+func levelStyle(level slog.Level) lipgloss.Style {
+	switch {
+	case level >= slog.LevelError:
+		return DisconnectedStyle
+	case level >= slog.LevelWarn:
+		return WarningStyle
+	case level >= slog.LevelInfo:
+		return lipgloss.NewStyle()
+	default:
+		return DimStyle
+	}
+}
+
+// toggleLogPane shows or hides the log pane
+func (a *App) toggleLogPane() {
+	if a.logRing == nil {
+		a.statusMsg = "No client log"
+		return
+	}
+	a.showLogPane = !a.showLogPane
+}
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/eventlog"
+)
+
+// maxEvents bounds the in-memory log; the file keeps everything
+const maxEvents = 1000
+
+// SetEventLog loads recent history from path and appends new events to it
+func (a *App) SetEventLog(path string) error {
+	entries, err := eventlog.Load(path, a.serverURL, a.username, maxEvents)
+	if err != nil {
+		return err
+	}
+	a.events = append(entries, a.events...)
+	a.eventsPath = path
+	return nil
+}
+
+// logEvent records an event and shows it in the status line
+func (a *App) logEvent(sev eventlog.Severity, kind, format string, args ...any) {
+	e := eventlog.Entry{
+		Time:     a.now(),
+		Severity: sev,
+		Kind:     kind,
+		Message:  fmt.Sprintf(format, args...),
+		Server:   a.serverURL,
+		Username: a.username,
+	}
+	a.statusMsg = e.Message
+
+	a.events = append(a.events, e)
+	if len(a.events) > maxEvents {
+		a.events = a.events[len(a.events)-maxEvents:]
+	}
+
+	if a.viewMode != viewLog {
+		a.unread++
+		a.unreadSeverity = max(a.unreadSeverity, sev)
+	}
+
+	if a.eventsPath != "" {
+		if err := eventlog.Append(a.eventsPath, e); err != nil {
+			a.statusMsg = fmt.Sprintf("Failed to save event: %v", err)
+		}
+	}
+}
+
+// markEventsRead clears the unread counter when the log is opened
+func (a *App) markEventsRead() {
+	a.unread = 0
+	a.unreadSeverity = eventlog.Info
+}
+
+// severityStyle colors entries and the unread badge
+func severityStyle(sev eventlog.Severity) lipgloss.Style {
+	switch sev {
+	case eventlog.Warn:
+		return WarningStyle
+	case eventlog.Alert:
+		return PoiContestedStyle.Bold(true)
+	case eventlog.Error:
+		return DisconnectedStyle
+	default:
+		return DimStyle
+	}
+}
+
+// unreadBadge is the tab bar's unread count, colored by the most severe
+// unread entry
+func (a *App) unreadBadge() string {
+	if a.unread == 0 {
+		return ""
+	}
+	return severityStyle(a.unreadSeverity).Render(fmt.Sprintf(" (%d)", a.unread))
+}
+
+// filteredEvents returns the entries passing the current filters, oldest first
+func (a *App) filteredEvents() []eventlog.Entry {
+	var out []eventlog.Entry
+	for _, e := range a.events {
+		if e.Matches(a.logMinSeverity, a.logFilter) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// handleLogKey scrolls and filters the log, reporting whether key was used
+func (a *App) handleLogKey(key string) bool {
+	switch key {
+	case "j", "down":
+		a.logScroll = max(a.logScroll-1, 0)
+	case "k", "up":
+		a.logScroll++
+	case "pgdown":
+		a.logScroll = max(a.logScroll-a.logRows(), 0)
+	case "pgup":
+		a.logScroll += a.logRows()
+	case "G", "end":
+		a.logScroll = 0
+	case "g", "home":
+		a.logScroll = len(a.events)
+	case "f":
+		a.logMinSeverity = (a.logMinSeverity + 1) % (eventlog.Error + 1)
+		a.logScroll = 0
+	case "/":
+		a.inputMode = "filter"
+		a.input.Placeholder = "Filter events..."
+		a.input.SetValue(a.logFilter)
+		a.input.CursorEnd()
+		a.input.Focus()
+	case "esc":
+		a.logFilter = ""
+		a.logMinSeverity = eventlog.Info
+		a.logScroll = 0
+	default:
+		return false
+	}
+	a.clampLogScroll()
+	return true
+}
+
+// clampLogScroll keeps the log from scrolling past its oldest entry
+func (a *App) clampLogScroll() {
+	a.logScroll = min(a.logScroll, max(len(a.filteredEvents())-a.logRows(), 0))
+}
+
+// logRows is how many entries fit on screen
+func (a *App) logRows() int {
+	if a.height > 0 {
+		return max(a.height-14, 5)
+	}
+	return 20
+}
+
+func (a *App) renderLogView() string {
+	var b strings.Builder
+
+	b.WriteString(LabelStyle.Render("EVENT LOG"))
+	b.WriteString(DimStyle.Render(fmt.Sprintf("  ≥ %s", a.logMinSeverity)))
+	if a.logFilter != "" {
+		b.WriteString(DimStyle.Render(fmt.Sprintf("  matching %q", a.logFilter)))
+	}
+	b.WriteString("\n\n")
+
+	events := a.filteredEvents()
+	if len(events) == 0 {
+		b.WriteString(DimStyle.Render("  No events"))
+		return b.String()
+	}
+
+	// Newest at the bottom; scroll counts entries up from the end
+	rows := a.logRows()
+	end := len(events) - min(a.logScroll, max(len(events)-rows, 0))
+	start := max(end-rows, 0)
+
+	for _, e := range events[start:end] {
+		style := severityStyle(e.Severity)
+		b.WriteString(fmt.Sprintf("  %s %s %s %s\n",
+			DimStyle.Render(e.Time.Format("Jan 02 15:04:05")),
+			style.Render(fmt.Sprintf("%-5s", e.Severity)),
+			DimStyle.Render(fmt.Sprintf("%-10s", e.Kind)),
+			e.Message))
+	}
+	b.WriteString(DimStyle.Render(fmt.Sprintf("  %d-%d of %d", start+1, end, len(events))))
+
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package tui
+
+import (
+	"github.com/philip/foam/internal/alert"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/eventlog"
+	"github.com/philip/foam/internal/market"
+)
+
+// App handles every server event; the assertion keeps it exhaustive
+var _ api.Handler = (*App)(nil)
+
+// OnConnected marks the session live (also after a reconnect)
+func (a *App) OnConnected(ev api.ConnectedEvent) {
+	if a.connState == stateReconnecting {
+		a.logEvent(eventlog.Info, eventlog.KindConnection, "Reconnected")
+	}
+	a.connState = stateConnected
+	a.reconnectAttempt = 0
+	a.err = nil
+}
+
+// OnState replaces our player snapshot
+func (a *App) OnState(ev api.StateEvent) {
+	player := ev.Player
+	a.player = &player
+}
+
+// OnTick updates nits and heat from the production tick
+func (a *App) OnTick(ev api.TickEvent) {
+	if a.player != nil {
+		a.player.Nits = ev.Nits
+		a.player.Heat = ev.Heat
+	}
+	a.pruneOutbound()
+
+	// Keep the book fresh while it's on screen
+	if a.viewMode == viewMarket {
+		a.marketStale = true
+	}
+}
+
+// OnHeatUpdate updates heat between ticks
+func (a *App) OnHeatUpdate(ev api.HeatUpdateEvent) {
+	if a.player != nil {
+		a.player.Heat = ev.Heat
+	}
+}
+
+// OnError surfaces a server-side error
+func (a *App) OnError(ev api.ErrorEvent) {
+	if a.onAuthError(ev) {
+		return
+	}
+	a.logEvent(eventlog.Error, eventlog.KindAction, "Error: %s", ev.Message)
+}
+
+// OnAck ignores acks for fire-and-forget sends; awaited requests get
+// theirs directly
+func (a *App) OnAck(ev api.AckEvent) {}
+
+// OnRouteRequest queues an inbound route request
+func (a *App) OnRouteRequest(ev api.RouteRequestEvent) {
+	a.pendingRequests = append(a.pendingRequests, routeRequest{from: ev.From, routeId: ev.RouteId})
+	a.logEvent(eventlog.Warn, eventlog.KindRoute, "Route request from %s!", ev.From)
+	a.raise(alert.RouteRequest, 0, "Route request from %s", ev.From)
+}
+
+// OnRouteAccepted adds a newly established route
+func (a *App) OnRouteAccepted(ev api.RouteAcceptedEvent) {
+	a.routes = append(a.routes, ev.Route)
+	if req := a.findOutbound(ev.RouteId); req != nil {
+		a.answerOutbound(ev.RouteId, outboundAccepted)
+		a.logEvent(eventlog.Info, eventlog.KindRoute, "%s accepted your route request!", req.to)
+	} else {
+		a.logEvent(eventlog.Info, eventlog.KindRoute, "Route established: %s ↔ %s", ev.Route.PlayerA, ev.Route.PlayerB)
+	}
+	// Answered from another session
+	a.removeRequest(ev.RouteId)
+	a.pruneOutbound()
+}
+
+// OnRouteRejected marks our request rejected, or drops an inbound request
+// rejected from another session
+func (a *App) OnRouteRejected(ev api.RouteRejectedEvent) {
+	if req := a.findOutbound(ev.RouteId); req != nil {
+		a.answerOutbound(ev.RouteId, outboundRejected)
+		a.logEvent(eventlog.Warn, eventlog.KindRoute, "%s rejected your route request", req.to)
+	}
+	a.removeRequest(ev.RouteId)
+	a.pruneOutbound()
+}
+
+// OnRouteWithdrawn drops an inbound request its sender took back
+func (a *App) OnRouteWithdrawn(ev api.RouteWithdrawnEvent) {
+	for _, req := range a.pendingRequests {
+		if req.routeId == ev.RouteId {
+			a.logEvent(eventlog.Info, eventlog.KindRoute, "%s withdrew their route request", req.from)
+		}
+	}
+	a.removeRequest(ev.RouteId)
+	// Withdrawn from another of our sessions
+	a.answerOutbound(ev.RouteId, outboundWithdrawn)
+	a.pruneOutbound()
+}
+
+// OnRoutes replaces the route list and, when the server sends them, the
+// pending route requests both ways
+func (a *App) OnRoutes(ev api.RoutesEvent) {
+	a.routes = ev.Routes
+	if ev.Requests != nil {
+		a.pendingRequests = nil
+		for _, req := range ev.Requests {
+			a.pendingRequests = append(a.pendingRequests, routeRequest{from: req.From, routeId: req.RouteId})
+		}
+	}
+	if ev.Outbound != nil {
+		a.syncOutbound(ev.Outbound)
+	}
+	a.clampRouteCursor()
+}
+
+// OnPois replaces the POI list and our control of them
+func (a *App) OnPois(ev api.PoisEvent) {
+	a.intersections = ev.Pois
+	a.controlledPois = nil
+	for _, poi := range ev.Pois {
+		if poi.Controller == a.me() {
+			a.controlledPois = append(a.controlledPois, poi.Id)
+		}
+	}
+	a.selectedPoi = min(a.selectedPoi, max(len(a.intersections)-1, 0))
+}
+
+// OnIntersectionCreated adds a new POI
+func (a *App) OnIntersectionCreated(ev api.IntersectionCreatedEvent) {
+	a.intersections = append(a.intersections, ev.Intersection)
+	poi := ev.Intersection
+	a.logEvent(eventlog.Info, eventlog.KindPoi, "New POI at %s", formatCoords(poi.Coordinates.Lat, poi.Coordinates.Lng))
+}
+
+// OnPoiUpdate updates an existing POI (or adds it) and tracks our control
+func (a *App) OnPoiUpdate(ev api.PoiUpdateEvent) {
+	poi := ev.Poi
+
+	// Update existing POI or add new one
+	found := false
+	for i, existing := range a.intersections {
+		if existing.Id == poi.Id {
+			a.intersections[i] = poi
+			found = true
+			break
+		}
+	}
+	if !found {
+		a.intersections = append(a.intersections, poi)
+	}
+
+	// Check if we control this POI
+	where := formatCoords(poi.Coordinates.Lat, poi.Coordinates.Lng)
+	if a.player != nil && poi.Controller == a.player.Username {
+		if !contains(a.controlledPois, poi.Id) {
+			a.controlledPois = append(a.controlledPois, poi.Id)
+			a.logEvent(eventlog.Info, eventlog.KindPoi, "You now control POI %s!", where)
+		}
+	} else if contains(a.controlledPois, poi.Id) {
+		a.controlledPois = remove(a.controlledPois, poi.Id)
+		lost := "Lost control of POI " + where
+		if poi.Controller != "" {
+			lost += " to " + poi.Controller
+		}
+		a.logEvent(eventlog.Alert, eventlog.KindPoi, "%s!", lost)
+		a.raise(alert.ControlLost, 0, "%s", lost)
+	}
+}
+
+// OnPoiContest reports an attack on a POI
+func (a *App) OnPoiContest(ev api.PoiContestEvent) {
+	a.logEvent(eventlog.Alert, eventlog.KindPoi, "POI contested by %s with %d nits!", ev.Attacker, ev.Amount)
+	if contains(a.controlledPois, ev.PoiId) {
+		a.raise(alert.POIAttacked, ev.Amount, "%s invested %d nits in your POI", ev.Attacker, ev.Amount)
+	}
+}
+
+// OnTollReceived accumulates toll income
+func (a *App) OnTollReceived(ev api.TollReceivedEvent) {
+	a.tollsReceived += ev.Amount
+	a.logEvent(eventlog.Info, eventlog.KindToll, "Received %d nits in tolls!", ev.Amount)
+	a.raise(alert.Toll, ev.Amount, "Received %d nits in tolls", ev.Amount)
+}
+
+// OnMarketUpdate replaces the order book and records its mid, which the
+// price chart shows between trades
+func (a *App) OnMarketUpdate(ev api.MarketUpdateEvent) {
+	a.marketBids = ev.Bids
+	a.marketAsks = ev.Asks
+	a.book.Sync(a.me(), ev.Bids, ev.Asks)
+	if mid, ok := market.Mid(ev.Bids, ev.Asks); ok {
+		a.mids.Add(market.Point{Time: a.now(), Price: mid})
+	}
+}
+
+// OnOrderFilled updates our orders, P&L and fill history
+func (a *App) OnOrderFilled(ev api.OrderFilledEvent) {
+	a.recordFill(ev)
+}
+
+// OnVisibilityUpdate tracks who is in view
+func (a *App) OnVisibilityUpdate(ev api.VisibilityUpdateEvent) {
+	a.updateVisibility(ev.VisiblePlayers)
+}
@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/rules"
+)
+
+// tierReach says who can see us in tier t
+func tierReach(t rules.Tier) string {
+	switch t.Hops {
+	case 0:
+		return "everyone, investments revealed"
+	case 1:
+		return "route partners only"
+	default:
+		return fmt.Sprintf("players %d hops away", t.Hops)
+	}
+}
+
+// renderHeatPanel shows our tier and when decay drops us into each lower one
+func (a *App) renderHeatPanel() string {
+	r := a.rules
+	heat := a.player.Heat
+	tier := r.TierIndex(heat)
+	style := lipgloss.NewStyle().Foreground(HeatColor(heat))
+
+	lines := []string{
+		LabelStyle.Render("HEAT FORECAST"),
+		"",
+		fmt.Sprintf("  %s %s", style.Render(HeatBar(heat)), style.Render(r.Tiers[tier].Name)),
+		DimStyle.Render("  Seen by " + tierReach(r.Tiers[tier])),
+		"",
+	}
+	for t := tier - 1; t >= 0; t-- {
+		lines = append(lines, fmt.Sprintf("  %-8s in %s", r.Tiers[t].Name, formatWait(r.CoolDown(heat, r.Tiers[t].MaxHeat))))
+	}
+	if heat > 0 {
+		lines = append(lines, DimStyle.Render(fmt.Sprintf("  %-8s in %s", "Cold", formatWait(r.CoolDown(heat, 0)))))
+	} else {
+		lines = append(lines, DimStyle.Render("  Cold"))
+	}
+
+	return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// heatPreview describes where an action's heat leaves us, e.g.
+// "Heat 48 → 53 (+5): Moderate → Hot, seen by players 3 hops away; back to
+// Moderate in 30s"
+func heatPreview(r rules.Params, heat, delta int) string {
+	after := r.HeatAfter(heat, delta)
+	from, to := r.TierIndex(heat), r.TierIndex(after)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Heat %d → %d (+%d): ", heat, after, after-heat)
+	if from == to {
+		fmt.Fprintf(&b, "stays %s", r.Tiers[to].Name)
+	} else {
+		fmt.Fprintf(&b, "%s → %s, seen by %s", r.Tiers[from].Name, r.Tiers[to].Name, tierReach(r.Tiers[to]))
+	}
+	if to > from {
+		fmt.Fprintf(&b, "; back to %s in %s", r.Tiers[from].Name, formatWait(r.CoolDown(after, r.Tiers[from].MaxHeat)))
+	}
+	return b.String()
+}
+
+// renderActionPreview previews the heat of the invest or order being typed
+func (a *App) renderActionPreview() string {
+	if a.player == nil {
+		return ""
+	}
+	r, heat := a.rules, a.player.Heat
+
+	var lines []string
+	switch a.inputMode {
+	case "invest":
+		if len(a.intersections) == 0 {
+			return ""
+		}
+		var amount int
+		fmt.Sscanf(a.input.Value(), "%d", &amount)
+		takes := a.wouldTakeControl(a.intersections[a.selectedPoi], amount)
+		if takes {
+			lines = append(lines, fmt.Sprintf("Takes control: +%d invest, +%d win", r.HeatInvest, r.HeatWin))
+		}
+		lines = append(lines, heatPreview(r, heat, r.InvestHeat(takes)))
+	case "bid", "ask", "amend":
+		lines = append(lines,
+			heatPreview(r, heat, r.TradeHeat(false)),
+			"If filled: "+heatPreview(r, heat, r.TradeHeat(true)),
+		)
+	default:
+		return ""
+	}
+
+	for i, l := range lines {
+		lines[i] = "  " + l
+	}
+	return DimStyle.Render(strings.Join(lines, "\n"))
+}
+
+// wouldTakeControl reports whether investing amount more in a POI we don't
+// control would make us its controller
+func (a *App) wouldTakeControl(poi api.IntersectionState, amount int) bool {
+	me := a.me()
+	return poi.Controller != me && a.rules.TakesControl(poi.Investments, me, amount)
+}
+
+// formatWait renders a countdown as "45s", "3m20s" or "1h05m"
+func formatWait(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/market"
+)
+
+// Depth ladder dimensions
+const (
+	ladderLevels = 8  // Price levels shown per side
+	ladderBar    = 16 // Width of a full cumulative depth bar
+)
+
+// depthColor brightens with cumulative depth, like NitBrightness does
+// with wealth
+func depthColor(frac float64) lipgloss.Color {
+	switch {
+	case frac < 0.25:
+		return ColorDim
+	case frac < 0.5:
+		return ColorNormal
+	case frac < 0.75:
+		return ColorBright
+	default:
+		return ColorGlow
+	}
+}
+
+// renderLadder shows the aggregated book: asks above (best at the bottom),
+// the spread and mid, then bids (best at the top)
+func (a *App) renderLadder() string {
+	bids, asks := market.Ladder(a.marketBids, a.marketAsks, a.me())
+	if len(bids) > ladderLevels {
+		bids = bids[:ladderLevels]
+	}
+	if len(asks) > ladderLevels {
+		asks = asks[:ladderLevels]
+	}
+
+	maxDepth := 1
+	if len(bids) > 0 {
+		maxDepth = max(maxDepth, bids[len(bids)-1].Cumulative)
+	}
+	if len(asks) > 0 {
+		maxDepth = max(maxDepth, asks[len(asks)-1].Cumulative)
+	}
+
+	row := func(l market.Level, priceStyle lipgloss.Style) string {
+		frac := float64(l.Cumulative) / float64(maxDepth)
+		filled := max(1, int(frac*ladderBar))
+		bar := lipgloss.NewStyle().Foreground(depthColor(frac)).Render(strings.Repeat("█", filled)) +
+			strings.Repeat(" ", ladderBar-filled)
+
+		mine := "  "
+		if l.Mine {
+			mine = NitStyle.Render(" ◂")
+			priceStyle = priceStyle.Bold(true).Underline(true)
+		}
+		return fmt.Sprintf("  %s %6d %s%s", priceStyle.Render(fmt.Sprintf("%7.2f", l.Price)), l.Size, bar, mine)
+	}
+
+	askStyle := lipgloss.NewStyle().Foreground(ColorDanger)
+	bidStyle := lipgloss.NewStyle().Foreground(ColorSuccess)
+
+	var lines []string
+	lines = append(lines,
+		LabelStyle.Render("BOOK"),
+		DimStyle.Render(fmt.Sprintf("  %7s %6s %s", "price", "size", "depth")))
+	if len(asks) == 0 {
+		lines = append(lines, DimStyle.Render("    No asks"))
+	}
+	for i := len(asks) - 1; i >= 0; i-- {
+		lines = append(lines, row(asks[i], askStyle))
+	}
+
+	spread := DimStyle.Render("  ─── no spread ───")
+	if len(bids) > 0 && len(asks) > 0 {
+		bid, ask := bids[0].Price, asks[0].Price
+		spread = DimStyle.Render(fmt.Sprintf("  ─── spread %.2f  mid %.2f ───", ask-bid, (ask+bid)/2))
+	}
+	lines = append(lines, spread)
+
+	for _, l := range bids {
+		lines = append(lines, row(l, bidStyle))
+	}
+	if len(bids) == 0 {
+		lines = append(lines, DimStyle.Render("    No bids"))
+	}
+
+	return strings.Join(lines, "\n")
+}
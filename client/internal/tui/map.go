@@ -0,0 +1,397 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/api"
+)
+
+// Map zoom limits, as degrees of longitude across the canvas
+const (
+	mapMinSpan = 0.01
+	mapMaxSpan = 360.0
+)
+
+// mapState is the map view's camera
+type mapState struct {
+	center api.Coordinates
+	span   float64 // Degrees of longitude across the canvas; 0 until first fitted
+	moved  bool    // Panned or zoomed by hand, so resizes keep the camera
+}
+
+// handleMapKey pans and zooms the map, reporting whether key was used
+func (a *App) handleMapKey(key string) bool {
+	if key == "0" {
+		a.fitMap()
+		return true
+	}
+	m := &a.mapView
+	if m.span == 0 {
+		a.fitMap()
+	}
+	step := m.span / 8
+
+	switch key {
+	case "h", "left":
+		m.center.Lng -= step
+	case "l", "right":
+		m.center.Lng += step
+	case "k", "up":
+		m.center.Lat += step
+	case "j", "down":
+		m.center.Lat -= step
+	case "+", "=":
+		m.span = math.Max(m.span/2, mapMinSpan)
+	case "-":
+		m.span = math.Min(m.span*2, mapMaxSpan)
+	default:
+		return false
+	}
+
+	m.center.Lat = math.Max(-90, math.Min(90, m.center.Lat))
+	m.moved = true
+	return true
+}
+
+// refitMap fits the camera again unless it was moved by hand; call it when
+// the terminal is resized or the map view opens
+func (a *App) refitMap() {
+	if !a.mapView.moved {
+		a.fitMap()
+	}
+}
+
+// fitMap centers the camera on everything visible
+func (a *App) fitMap() {
+	a.mapView = a.mapFit(a.mapSize())
+}
+
+// mapSize is the map canvas in cells, leaving room for the container and
+// panel borders, header, legend and help
+func (a *App) mapSize() (w, h int) {
+	w, h = 72, 18
+	if a.width > 0 {
+		w = max(a.width-10, 20)
+	}
+	if a.height > 0 {
+		h = max(a.height-16, 6)
+	}
+	return w, h
+}
+
+// mapPoints lists everything we can see: HOME, route endpoints, the POIs on
+// our routes and players revealed by their heat. Nothing else is known to
+// the client, which is the fog.
+func (a *App) mapPoints() []api.Coordinates {
+	var pts []api.Coordinates
+	if a.player != nil {
+		pts = append(pts, a.player.Coordinates)
+	}
+	for _, r := range a.routes {
+		pts = append(pts, r.CoordsA, r.CoordsB)
+	}
+	for _, poi := range a.intersections {
+		pts = append(pts, poi.Coordinates)
+	}
+	for _, p := range a.visiblePlayers {
+		pts = append(pts, p.Coordinates)
+	}
+	return pts
+}
+
+// mapFit returns a camera centered on everything visible on a w by h canvas
+func (a *App) mapFit(w, h int) mapState {
+	pts := a.mapPoints()
+	if len(pts) == 0 {
+		return mapState{span: mapMaxSpan}
+	}
+
+	minLat, maxLat := pts[0].Lat, pts[0].Lat
+	minLng, maxLng := pts[0].Lng, pts[0].Lng
+	for _, p := range pts[1:] {
+		minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+		minLng, maxLng = math.Min(minLng, p.Lng), math.Max(maxLng, p.Lng)
+	}
+
+	center := api.Coordinates{Lat: (minLat + maxLat) / 2, Lng: (minLng + maxLng) / 2}
+	cos := math.Max(math.Cos(center.Lat*math.Pi/180), 0.1)
+
+	// Braille dots are roughly square, so the canvas is w*2 by h*4 dots
+	span := math.Max((maxLng-minLng)*cos, (maxLat-minLat)*float64(w*2)/float64(h*4)) * 1.25
+	return mapState{
+		center: center,
+		span:   math.Max(mapMinSpan*10, math.Min(span, mapMaxSpan)),
+	}
+}
+
+func (a *App) renderMapView() string {
+	w, h := a.mapSize()
+	view := a.mapView
+	if view.span == 0 {
+		view = a.mapFit(w, h)
+	}
+
+	c := newBrailleCanvas(w, h)
+	proj := view.projection(w, h)
+
+	// Routes first so markers sit on top
+	for _, r := range a.routes {
+		style := DimStyle
+		if r.Status == "active" {
+			style = LabelStyle
+		}
+		x0, y0 := proj(r.CoordsA)
+		x1, y1 := proj(r.CoordsB)
+		c.line(x0, y0, x1, y1, style)
+	}
+
+	me := a.me()
+
+	// Players revealed by their heat, colored by it
+	for _, p := range a.visiblePlayers {
+		style := lipgloss.NewStyle().Foreground(HeatColor(p.Heat))
+		x, y := proj(p.Coordinates)
+		c.marker(x, y, '○', style)
+		c.label(x, y, p.Username, style)
+	}
+
+	// Route partners, labeled where there's room
+	for _, r := range a.routes {
+		other, coords := r.PlayerB, r.CoordsB
+		if other == me {
+			other, coords = r.PlayerA, r.CoordsA
+		}
+		x, y := proj(coords)
+		c.marker(x, y, '●', ConnectedStyle)
+		c.label(x, y, other, DimStyle)
+	}
+
+	for _, poi := range a.intersections {
+		style := PoiUnclaimedStyle
+		switch poi.Controller {
+		case "":
+		case me:
+			style = PoiControlledStyle
+		default:
+			style = PoiContestedStyle
+		}
+		x, y := proj(poi.Coordinates)
+		c.marker(x, y, '◆', style)
+	}
+
+	if a.player != nil {
+		x, y := proj(a.player.Coordinates)
+		c.marker(x, y, '◉', NitStyle)
+		c.label(x, y, "HOME", NitStyle)
+	}
+
+	var b strings.Builder
+	b.WriteString(LabelStyle.Render("MAP"))
+	b.WriteString(DimStyle.Render(fmt.Sprintf("  %s  ·  %.0f km across",
+		formatCoords(view.center.Lat, view.center.Lng),
+		view.span*111.32)))
+	b.WriteString("\n\n")
+	b.WriteString(PanelStyle.Render(c.String()))
+	b.WriteString("\n")
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		NitStyle.Render("◉ home  "),
+		ConnectedStyle.Render("● route partner  "),
+		DimStyle.Render("○ visible  "),
+		PoiControlledStyle.Render("◆ yours  "),
+		PoiContestedStyle.Render("◆ contested  "),
+		PoiUnclaimedStyle.Render("◆ unclaimed"),
+	))
+
+	return b.String()
+}
+
+// projection maps coordinates to canvas dots with an equirectangular
+// projection scaled by cos(latitude) at the camera's center
+func (m mapState) projection(w, h int) func(api.Coordinates) (int, int) {
+	cos := math.Max(math.Cos(m.center.Lat*math.Pi/180), 0.1)
+	perDot := m.span / float64(w*2)
+	return func(p api.Coordinates) (int, int) {
+		dLng := p.Lng - m.center.Lng
+		// Take the short way around the antimeridian
+		if dLng > 180 {
+			dLng -= 360
+		} else if dLng < -180 {
+			dLng += 360
+		}
+		x := dLng*cos/perDot + float64(w)
+		y := (m.center.Lat-p.Lat)/perDot + float64(h*2)
+		return int(math.Round(x)), int(math.Round(y))
+	}
+}
+
+// brailleCanvas draws lines at 2x4 dots per terminal cell, with glyphs and
+// labels overlaid per cell
+type brailleCanvas struct {
+	w, h  int
+	cells []brailleCell
+}
+
+type brailleCell struct {
+	dots  uint8
+	glyph rune // Overrides dots when set
+	style lipgloss.Style
+}
+
+// brailleBits maps a dot's (x, y) within a cell to its bit in U+2800
+var brailleBits = [2][4]uint8{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+func newBrailleCanvas(w, h int) *brailleCanvas {
+	return &brailleCanvas{w: w, h: h, cells: make([]brailleCell, w*h)}
+}
+
+// cell returns the cell containing dot (x, y), or nil off-canvas
+func (c *brailleCanvas) cell(x, y int) *brailleCell {
+	if x < 0 || y < 0 || x >= c.w*2 || y >= c.h*4 {
+		return nil
+	}
+	return &c.cells[(y/4)*c.w+x/2]
+}
+
+func (c *brailleCanvas) set(x, y int, style lipgloss.Style) {
+	cell := c.cell(x, y)
+	if cell == nil {
+		return
+	}
+	cell.dots |= brailleBits[x%2][y%4]
+	cell.style = style
+}
+
+// line draws a segment, clipped to the canvas first so far-off endpoints
+// at high zoom stay cheap
+func (c *brailleCanvas) line(x0, y0, x1, y1 int, style lipgloss.Style) {
+	fx0, fy0, fx1, fy1, ok := clipLine(float64(x0), float64(y0), float64(x1), float64(y1),
+		0, 0, float64(c.w*2-1), float64(c.h*4-1))
+	if !ok {
+		return
+	}
+	x0, y0 = int(math.Round(fx0)), int(math.Round(fy0))
+	x1, y1 = int(math.Round(fx1)), int(math.Round(fy1))
+
+	// Bresenham
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.set(x0, y0, style)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// marker places a glyph on the cell containing dot (x, y)
+func (c *brailleCanvas) marker(x, y int, glyph rune, style lipgloss.Style) {
+	cell := c.cell(x, y)
+	if cell == nil {
+		return
+	}
+	cell.glyph, cell.style = glyph, style
+}
+
+// label writes text to the right of dot (x, y) if those cells are free
+func (c *brailleCanvas) label(x, y int, text string, style lipgloss.Style) {
+	if c.cell(x, y) == nil {
+		return
+	}
+	row, col := y/4, x/2+2
+	runes := []rune(text)
+	if col+len(runes) > c.w {
+		return
+	}
+	for i := range runes {
+		if cell := &c.cells[row*c.w+col+i]; cell.glyph != 0 {
+			return
+		}
+	}
+	for i, r := range runes {
+		cell := &c.cells[row*c.w+col+i]
+		cell.glyph, cell.style = r, style
+	}
+}
+
+func (c *brailleCanvas) String() string {
+	var b strings.Builder
+	for row := 0; row < c.h; row++ {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		for col := 0; col < c.w; col++ {
+			cell := c.cells[row*c.w+col]
+			switch {
+			case cell.glyph != 0:
+				b.WriteString(cell.style.Render(string(cell.glyph)))
+			case cell.dots != 0:
+				b.WriteString(cell.style.Render(string(rune(0x2800 + int(cell.dots)))))
+			default:
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return b.String()
+}
+
+// clipLine clips a segment to a rectangle (Liang-Barsky)
+func clipLine(x0, y0, x1, y1, minX, minY, maxX, maxY float64) (float64, float64, float64, float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := x1-x0, y1-y0
+	for _, edge := range [4][2]float64{
+		{-dx, x0 - minX},
+		{dx, maxX - x0},
+		{-dy, y0 - minY},
+		{dy, maxY - y0},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, 0, 0, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return 0, 0, 0, 0, false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return 0, 0, 0, 0, false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	return x0 + t0*dx, y0 + t0*dy, x0 + t1*dx, y0 + t1*dy, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,301 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/eventlog"
+	"github.com/philip/foam/internal/market"
+	"github.com/philip/foam/internal/trades"
+)
+
+// blotterRows caps the orders and fills listed in the market view
+const blotterRows = 5
+
+// marketMsg carries a fresh order book snapshot
+type marketMsg struct {
+	snap api.MarketSnapshot
+	err  error
+}
+
+// SetFillsLog loads our fill history from path and appends new fills to it
+func (a *App) SetFillsLog(path string) error {
+	fills, err := trades.LoadFills(path, a.serverURL, a.username)
+	if err != nil {
+		return err
+	}
+	a.book.Load(fills)
+	a.fillsPath = path
+	return nil
+}
+
+// refreshMarket fetches the order book; the server doesn't push it
+func (a *App) refreshMarket() tea.Cmd {
+	if a.replay != nil {
+		return nil // The recording has the snapshots the session fetched
+	}
+	serverURL := a.serverURL
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		snap, err := api.FetchMarket(ctx, serverURL)
+		return marketMsg{snap: snap, err: err}
+	}
+}
+
+// handleMarket applies a snapshot to the book and to our orders
+func (a *App) handleMarket(msg marketMsg) {
+	if msg.err != nil {
+		a.log().Warn("market fetch failed", "err", msg.err)
+		a.statusMsg = fmt.Sprintf("Failed to load market: %v", msg.err)
+		return
+	}
+	if a.recorder != nil {
+		a.recorder.RecordMarket(msg.snap)
+	}
+	a.OnMarketUpdate(api.MarketUpdateEvent{Bids: msg.snap.Bids, Asks: msg.snap.Asks})
+	a.backfillPrices(msg.snap.PriceHistory)
+}
+
+// placeOrder sends an order and starts tracking it once acknowledged
+func (a *App) placeOrder(side string, price float64, amount int) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		ack, err := client.PlaceOrderContext(context.Background(), side, price, amount)
+		return sendResultMsg{
+			action:  "place " + side + " order",
+			confirm: fmt.Sprintf("Order placed: %s %d nits @ %.2f", side, amount, price),
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, ack api.AckEvent) tea.Cmd {
+				if ack.OrderId != "" {
+					a.book.Place(trades.Order{
+						Id:       ack.OrderId,
+						Side:     side,
+						Price:    price,
+						Amount:   amount,
+						PlacedAt: a.now(),
+					})
+				}
+				return a.refreshMarket()
+			},
+		}
+	}
+}
+
+// recordFill applies a fill to our book and appends it to the history
+func (a *App) recordFill(ev api.OrderFilledEvent) {
+	fill := trades.Fill{
+		Time:     a.now(),
+		Server:   a.serverURL,
+		Username: a.username,
+		OrderId:  ev.OrderId,
+		Side:     ev.Side,
+		Amount:   ev.Amount,
+		Price:    ev.Price,
+	}
+
+	a.prices.Add(market.Point{Time: fill.Time, Price: fill.Price})
+
+	order := a.book.Apply(fill)
+	if order != nil {
+		fill.Side = order.Side
+	}
+
+	verb := "Filled"
+	switch fill.Side {
+	case "bid":
+		verb = "Bought"
+	case "ask":
+		verb = "Sold"
+	}
+	text := fmt.Sprintf("%s %d nits @ %.2f", verb, fill.Amount, fill.Price)
+	if order != nil && order.Status == trades.StatusPartial {
+		text += fmt.Sprintf(" (%d/%d filled)", order.Filled, order.Amount)
+	}
+	a.logEvent(eventlog.Info, eventlog.KindMarket, "%s", text)
+
+	if a.fillsPath != "" {
+		if err := trades.AppendFill(a.fillsPath, fill); err != nil {
+			a.statusMsg = fmt.Sprintf("Failed to save fill: %v", err)
+		}
+	}
+	a.marketStale = true
+}
+
+// selectedOrder returns the open order under the cursor
+func (a *App) selectedOrder() (trades.Order, bool) {
+	open := a.book.Open()
+	if len(open) == 0 {
+		return trades.Order{}, false
+	}
+	a.orderCursor = min(a.orderCursor, len(open)-1)
+	return open[a.orderCursor], true
+}
+
+// moveOrderCursor steps the cursor through our open orders
+func (a *App) moveOrderCursor(delta int) {
+	if n := len(a.book.Open()); n > 0 {
+		a.orderCursor = (a.orderCursor + delta + n) % n
+	}
+}
+
+// cancelOrder cancels one of our orders
+func (a *App) cancelOrder(o trades.Order) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		ack, err := client.CancelOrderContext(context.Background(), o.Id)
+		return sendResultMsg{
+			action:  "cancel order",
+			confirm: fmt.Sprintf("Cancelled %s %d @ %.2f", o.Side, o.Remaining(), o.Price),
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, _ api.AckEvent) tea.Cmd {
+				a.book.Cancel(o.Id)
+				return a.refreshMarket()
+			},
+		}
+	}
+}
+
+// amendOrder replaces an order: cancel, then place the new price and size.
+// If the cancel fails (typically because it filled) nothing is placed. Once
+// the cancel is acked the original is gone, even if the new order is then
+// rejected.
+func (a *App) amendOrder(o trades.Order, price float64, amount int) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		ctx := context.Background()
+		if _, err := client.CancelOrderContext(ctx, o.Id); err != nil {
+			return sendResultMsg{action: "amend order", err: err}
+		}
+
+		ack, err := client.PlaceOrderContext(ctx, o.Side, price, amount)
+		if err != nil {
+			return sendResultMsg{
+				action: "amend order",
+				onAck: func(a *App, _ api.AckEvent) tea.Cmd {
+					a.book.Cancel(o.Id)
+					a.logEvent(eventlog.Error, eventlog.KindAction, "Order cancelled, replacement rejected: %v", err)
+					return a.refreshMarket()
+				},
+			}
+		}
+		return sendResultMsg{
+			action:  "amend order",
+			confirm: fmt.Sprintf("Amended: %s %d nits @ %.2f", o.Side, amount, price),
+			ack:     ack,
+			onAck: func(a *App, ack api.AckEvent) tea.Cmd {
+				a.book.Cancel(o.Id)
+				if ack.OrderId != "" {
+					a.book.Place(trades.Order{
+						Id:       ack.OrderId,
+						Side:     o.Side,
+						Price:    price,
+						Amount:   amount,
+						PlacedAt: a.now(),
+					})
+				}
+				return a.refreshMarket()
+			},
+		}
+	}
+}
+
+// cancelAllOrders is the panic button: cancel every open order we know of
+func (a *App) cancelAllOrders() tea.Cmd {
+	client, open := a.client, a.book.Open()
+	return func() tea.Msg {
+		var cancelled []string
+		var errs []error
+		for _, o := range open {
+			if _, err := client.CancelOrderContext(context.Background(), o.Id); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", o.Id, err))
+				continue
+			}
+			cancelled = append(cancelled, o.Id)
+		}
+
+		// Successes and failures are reported separately, by count of acks
+		onAck := func(a *App, _ api.AckEvent) tea.Cmd {
+			for _, id := range cancelled {
+				a.book.Cancel(id)
+			}
+			if len(cancelled) > 0 {
+				a.logEvent(eventlog.Info, eventlog.KindAction, "Cancelled %d of %d orders", len(cancelled), len(open))
+			}
+			if len(errs) > 0 {
+				a.logEvent(eventlog.Error, eventlog.KindAction, "Failed to cancel %d of %d orders: %v", len(errs), len(open), errors.Join(errs...))
+			}
+			return a.refreshMarket()
+		}
+		return sendResultMsg{action: "cancel all orders", onAck: onAck}
+	}
+}
+
+// renderBlotter shows our orders, recent fills and P&L
+func (a *App) renderBlotter() string {
+	var b strings.Builder
+
+	b.WriteString(LabelStyle.Render("MY ORDERS"))
+	b.WriteString("\n")
+
+	// Open orders are selectable; finished ones follow for reference
+	open := a.book.Open()
+	if len(open) == 0 {
+		b.WriteString(DimStyle.Render("    No open orders\n"))
+	}
+	for i, o := range open {
+		cursor := "  "
+		if i == a.orderCursor {
+			cursor = TabActiveStyle.Render("▸ ")
+		}
+		b.WriteString(fmt.Sprintf("  %s%-3s %.2f × %d  %d/%d  %s\n",
+			cursor, o.Side, o.Price, o.Amount, o.Filled, o.Amount, WarningStyle.Render(o.Status)))
+	}
+
+	shown := 0
+	for _, o := range a.book.Orders() {
+		if o.Active() {
+			continue
+		}
+		if shown == blotterRows {
+			break
+		}
+		shown++
+		status := DimStyle.Render(o.Status)
+		if o.Status == trades.StatusFilled {
+			status = ConnectedStyle.Render(o.Status)
+		}
+		b.WriteString(DimStyle.Render(fmt.Sprintf("    %-3s %.2f × %d  %d/%d  ",
+			o.Side, o.Price, o.Amount, o.Filled, o.Amount)) + status + "\n")
+	}
+
+	fills := a.book.Fills()
+	if len(fills) > 0 {
+		b.WriteString("\n")
+		b.WriteString(LabelStyle.Render("RECENT FILLS"))
+		b.WriteString("\n")
+		for i := len(fills) - 1; i >= 0 && i >= len(fills)-blotterRows; i-- {
+			f := fills[i]
+			b.WriteString(fmt.Sprintf("    %s  %-3s %d @ %.2f\n",
+				DimStyle.Render(f.Time.Format("Jan 02 15:04")), f.Side, f.Amount, f.Price))
+		}
+	}
+
+	s := a.book.Stats()
+	pnlStyle := ConnectedStyle
+	if s.Realized < 0 {
+		pnlStyle = DisconnectedStyle
+	}
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("  bought %d  sold %d  held %d @ %.2f avg  P&L %s",
+		s.Bought, s.Sold, s.Position, s.AvgCost, pnlStyle.Render(fmt.Sprintf("%+.2f", s.Realized))))
+
+	return b.String()
+}
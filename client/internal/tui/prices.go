@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/market"
+)
+
+// chartWindows are the market chart's selectable time spans
+var chartWindows = []struct {
+	label string
+	span  time.Duration
+}{
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+// tickerPoints is how many recent trades the header sparkline shows
+const tickerPoints = 12
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// backfillPrices merges the server's price history into ours
+func (a *App) backfillPrices(history []api.PricePoint) {
+	points := make([]market.Point, len(history))
+	for i, p := range history {
+		points[i] = market.Point{Time: time.UnixMilli(p.Timestamp), Price: p.Price}
+	}
+	a.prices.Backfill(points)
+}
+
+// renderTicker is the header's sparkline of recent trades with the last
+// price, colored by direction
+func (a *App) renderTicker() string {
+	tail := a.prices.Tail(tickerPoints)
+	if len(tail) == 0 {
+		return ""
+	}
+
+	lo, hi := tail[0].Price, tail[0].Price
+	for _, p := range tail {
+		lo, hi = math.Min(lo, p.Price), math.Max(hi, p.Price)
+	}
+
+	var spark strings.Builder
+	for _, p := range tail {
+		i := len(sparkBlocks) / 2
+		if hi > lo {
+			i = int((p.Price - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		spark.WriteRune(sparkBlocks[i])
+	}
+
+	last := tail[len(tail)-1].Price
+	style := DimStyle
+	switch {
+	case last > tail[0].Price:
+		style = ConnectedStyle
+	case last < tail[0].Price:
+		style = DisconnectedStyle
+	}
+	return style.Render(spark.String()) + " " + NitStyle.Render(fmt.Sprintf("%.2f", last))
+}
+
+// renderPriceChart draws trade candles for the selected window with a
+// price axis, and the book's mid as dots where no trade is drawn
+func (a *App) renderPriceChart(w, h int) string {
+	window := chartWindows[a.chartWindow]
+
+	var tabs []string
+	for i, win := range chartWindows {
+		if i == a.chartWindow {
+			tabs = append(tabs, TabActiveStyle.Render(win.label))
+		} else {
+			tabs = append(tabs, TabStyle.Render(win.label))
+		}
+	}
+
+	title := LabelStyle.Render("PRICE") + "  " + strings.Join(tabs, " ")
+	if last, ok := a.prices.Last(); ok {
+		title += DimStyle.Render(fmt.Sprintf("  last %.2f", last.Price))
+	}
+	if mid, ok := market.Mid(a.marketBids, a.marketAsks); ok {
+		title += DimStyle.Render(fmt.Sprintf("  mid %.2f", mid))
+	}
+
+	candles := a.prices.Candles(a.now(), window.span, w)
+	mids := a.mids.Candles(a.now(), window.span, w)
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, series := range [][]market.Candle{candles, mids} {
+		for _, c := range series {
+			if c.High == 0 {
+				continue // Before the first price we know of
+			}
+			lo, hi = math.Min(lo, c.Low), math.Max(hi, c.High)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		return title + "\n" + DimStyle.Render("  No trades yet")
+	}
+	if hi == lo {
+		lo, hi = lo*0.95, hi*1.05
+	}
+
+	up := lipgloss.NewStyle().Foreground(ColorSuccess)
+	down := lipgloss.NewStyle().Foreground(ColorDanger)
+
+	rows := make([]string, h)
+	step := (hi - lo) / float64(h)
+	for r := 0; r < h; r++ {
+		// Row r covers [rowLo, rowHi], top row highest
+		rowHi := hi - float64(r)*step
+		rowLo := rowHi - step
+
+		var line strings.Builder
+		for i, c := range candles {
+			mid := mids[i].Close
+			switch {
+			case c.High == 0 || c.High < rowLo || c.Low > rowHi:
+				if mid != 0 && mid >= rowLo && mid <= rowHi {
+					line.WriteString(DimStyle.Render("·"))
+				} else {
+					line.WriteByte(' ')
+				}
+			case c.Trades == 0:
+				line.WriteString(DimStyle.Render("─"))
+			case math.Max(c.Open, c.Close) >= rowLo && math.Min(c.Open, c.Close) <= rowHi:
+				style := up
+				if c.Close < c.Open {
+					style = down
+				}
+				line.WriteString(style.Render("┃"))
+			default:
+				line.WriteString(DimStyle.Render("│"))
+			}
+		}
+
+		axis := ""
+		switch r {
+		case 0:
+			axis = fmt.Sprintf(" %.2f", hi)
+		case h / 2:
+			axis = fmt.Sprintf(" %.2f", (hi+lo)/2)
+		case h - 1:
+			axis = fmt.Sprintf(" %.2f", lo)
+		}
+		rows[r] = line.String() + DimStyle.Render(axis)
+	}
+
+	return title + "\n" + strings.Join(rows, "\n")
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/eventlog"
+)
+
+// replaySpeeds are the playback rates < and > step through
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8, 16, 64}
+
+// replayState plays a recording back instead of a live connection
+type replayState struct {
+	frames []api.Frame
+	next   int // Index of the next frame to play
+	speed  int // Index into replaySpeeds
+	paused bool
+	gen    int       // Bumped to drop the frame already scheduled
+	clock  time.Time // Time of the last frame played
+}
+
+// replayMsg plays the next frame, unless gen is out of date
+type replayMsg struct{ gen int }
+
+// NewReplay creates an App that plays frames back at speed (1 is real
+// time) without connecting to a server. Actions fail as not connected.
+func NewReplay(frames []api.Frame, speed float64) *App {
+	username := ""
+	for _, f := range frames {
+		if f.Dir != api.DirIn {
+			continue
+		}
+		if ev, err := api.Decode(f.Bytes()); err == nil {
+			if c, ok := ev.(api.ConnectedEvent); ok {
+				username = c.Username
+				break
+			}
+		}
+	}
+
+	a := NewApp("", username)
+	a.client = api.NewClient("", username) // Never connected, so sends fail cleanly
+	a.replay = &replayState{frames: frames, speed: closestSpeed(speed)}
+	if len(frames) > 0 {
+		a.replay.clock = frames[0].Time
+	}
+	return a
+}
+
+// closestSpeed returns the index of the listed speed nearest to speed
+func closestSpeed(speed float64) int {
+	best := 0
+	for i, s := range replaySpeeds {
+		if math.Abs(s-speed) < math.Abs(replaySpeeds[best]-speed) {
+			best = i
+		}
+	}
+	return best
+}
+
+// now is the wall clock, or the recording's clock during a replay
+func (a *App) now() time.Time {
+	if a.replay != nil {
+		return a.replay.clock
+	}
+	return time.Now()
+}
+
+// scheduleReplay waits out the recorded gap before the next frame
+func (a *App) scheduleReplay() tea.Cmd {
+	r := a.replay
+	if r.paused || r.next >= len(r.frames) {
+		return nil
+	}
+	var delay time.Duration
+	if r.next > 0 {
+		gap := r.frames[r.next].Time.Sub(r.frames[r.next-1].Time)
+		delay = time.Duration(float64(gap) / replaySpeeds[r.speed])
+	}
+	gen := r.gen
+	return tea.Tick(delay, func(time.Time) tea.Msg { return replayMsg{gen} })
+}
+
+// handleReplayMsg plays a scheduled frame and schedules the one after it
+func (a *App) handleReplayMsg(msg replayMsg) tea.Cmd {
+	if msg.gen != a.replay.gen {
+		return nil
+	}
+	return tea.Batch(a.playFrame(), a.scheduleReplay())
+}
+
+// playFrame applies the next frame as the live app would have
+func (a *App) playFrame() tea.Cmd {
+	r := a.replay
+	if r.next >= len(r.frames) {
+		return nil
+	}
+	f := r.frames[r.next]
+	r.next++
+	r.clock = f.Time
+
+	switch f.Dir {
+	case api.DirIn:
+		ev, err := api.Decode(f.Bytes())
+		if err != nil {
+			a.logEvent(eventlog.Warn, eventlog.KindConnection, "Unreadable frame: %v", err)
+			return nil
+		}
+		if _, ok := ev.(api.PongEvent); ok {
+			return nil
+		}
+		return a.handleEvent(ev)
+
+	case api.DirOut:
+		var msg api.ClientMessage
+		if err := json.Unmarshal(f.Bytes(), &msg); err != nil || msg.Type == "ping" || msg.Type == "auth" {
+			return nil
+		}
+		a.logEvent(eventlog.Info, eventlog.KindAction, "Sent %s", describeSent(msg))
+
+	case api.DirMarket:
+		var snap api.MarketSnapshot
+		if err := json.Unmarshal(f.Bytes(), &snap); err == nil {
+			a.handleMarket(marketMsg{snap: snap})
+		}
+	}
+	return nil
+}
+
+// describeSent summarizes an outbound message for the log
+func describeSent(msg api.ClientMessage) string {
+	switch msg.Type {
+	case "request_route":
+		return fmt.Sprintf("route request to %s", msg.To)
+	case "accept_route", "reject_route", "withdraw_route", "upgrade_route":
+		return fmt.Sprintf("%s %s", msg.Type, msg.RouteId)
+	case "place_order":
+		return fmt.Sprintf("%s %d @ %.2f", msg.Side, msg.Amount, msg.Price)
+	case "cancel_order":
+		return "cancel " + msg.OrderId
+	case "invest_poi":
+		return fmt.Sprintf("invest %d in %s", msg.Amount, msg.PoiId)
+	default:
+		return msg.Type
+	}
+}
+
+// handleReplayKey handles playback keys, reporting whether key was one
+func (a *App) handleReplayKey(key string) (tea.Cmd, bool) {
+	r := a.replay
+	switch key {
+	case " ":
+		r.paused = !r.paused
+		r.gen++
+		return a.scheduleReplay(), true
+	case ".":
+		// Step pauses first, so the frame after it waits for the next step
+		r.paused = true
+		r.gen++
+		return a.playFrame(), true
+	case ">":
+		r.speed = min(r.speed+1, len(replaySpeeds)-1)
+	case "<":
+		r.speed = max(r.speed-1, 0)
+	default:
+		return nil, false
+	}
+	// A new speed applies from the next frame on
+	r.gen++
+	return a.scheduleReplay(), true
+}
+
+// renderReplay shows playback position, speed and the recording's clock
+// where the live app shows link health
+func (a *App) renderReplay() string {
+	r := a.replay
+	state := "▶"
+	if r.paused {
+		state = "⏸"
+	}
+	if r.next >= len(r.frames) {
+		state = "■"
+	}
+	return WarningStyle.Render(fmt.Sprintf("%s replay %gx %d/%d %s",
+		state, replaySpeeds[r.speed], r.next, len(r.frames), r.clock.Format("15:04:05")))
+}
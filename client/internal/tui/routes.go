@@ -0,0 +1,411 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/geo"
+)
+
+// outboundShownFor is how long an answered outbound request stays listed
+const outboundShownFor = 5 * time.Minute
+
+// routeRequest is an inbound route request awaiting our answer
+type routeRequest struct {
+	from    string
+	routeId string
+}
+
+// Outbound request statuses
+const (
+	outboundAwaiting  = "awaiting"
+	outboundAccepted  = "accepted"
+	outboundRejected  = "rejected"
+	outboundWithdrawn = "withdrawn"
+)
+
+// outboundRequest is a route request we sent
+type outboundRequest struct {
+	to         string
+	routeId    string
+	sentAt     time.Time
+	status     string
+	answeredAt time.Time
+}
+
+// routeItems counts the selectable rows: inbound requests, outbound
+// requests, then routes
+func (a *App) routeItems() int {
+	return len(a.pendingRequests) + len(a.outboundRequests) + len(a.routes)
+}
+
+// moveRouteCursor steps through the routes view's rows
+func (a *App) moveRouteCursor(delta int) {
+	n := a.routeItems()
+	if n == 0 {
+		return
+	}
+	a.routeCursor = (a.routeCursor + delta + n) % n
+	a.upgradeArmed = ""
+}
+
+// clampRouteCursor keeps the cursor in range after rows disappear
+func (a *App) clampRouteCursor() {
+	if n := a.routeItems(); a.routeCursor >= n {
+		a.routeCursor = max(n-1, 0)
+	}
+}
+
+// syncOutbound matches our outbound requests to the server's list: ones we
+// lost track of come back as awaiting, and awaiting ones the server no
+// longer has are dropped. Answered ones stay until they age out.
+func (a *App) syncOutbound(pending []api.OutboundRequest) {
+	live := make(map[string]bool, len(pending))
+	for _, req := range pending {
+		live[req.RouteId] = true
+	}
+	kept := a.outboundRequests[:0]
+	known := make(map[string]bool, len(a.outboundRequests))
+	for _, req := range a.outboundRequests {
+		if req.status == outboundAwaiting && !live[req.routeId] {
+			continue
+		}
+		known[req.routeId] = true
+		kept = append(kept, req)
+	}
+	for _, req := range pending {
+		if !known[req.RouteId] {
+			kept = append(kept, outboundRequest{to: req.To, routeId: req.RouteId, sentAt: a.now(), status: outboundAwaiting})
+		}
+	}
+	a.outboundRequests = kept
+}
+
+// selectedRequest returns the pending request under the cursor, if any
+func (a *App) selectedRequest() (routeRequest, bool) {
+	if a.routeCursor < len(a.pendingRequests) {
+		return a.pendingRequests[a.routeCursor], true
+	}
+	return routeRequest{}, false
+}
+
+// selectedOutbound returns the outbound request under the cursor, if any
+func (a *App) selectedOutbound() (*outboundRequest, bool) {
+	i := a.routeCursor - len(a.pendingRequests)
+	if i >= 0 && i < len(a.outboundRequests) {
+		return &a.outboundRequests[i], true
+	}
+	return nil, false
+}
+
+// selectedRoute returns the route under the cursor, if any
+func (a *App) selectedRoute() (*api.RouteState, bool) {
+	i := a.routeCursor - len(a.pendingRequests) - len(a.outboundRequests)
+	if i >= 0 && i < len(a.routes) {
+		return &a.routes[i], true
+	}
+	return nil, false
+}
+
+// removeRequest drops an answered inbound request, reporting whether it
+// was there
+func (a *App) removeRequest(routeId string) bool {
+	for i, req := range a.pendingRequests {
+		if req.routeId == routeId {
+			a.pendingRequests = append(a.pendingRequests[:i], a.pendingRequests[i+1:]...)
+			a.clampRouteCursor()
+			return true
+		}
+	}
+	return false
+}
+
+// findOutbound returns our outbound request with routeId, if any
+func (a *App) findOutbound(routeId string) *outboundRequest {
+	for i := range a.outboundRequests {
+		if a.outboundRequests[i].routeId == routeId {
+			return &a.outboundRequests[i]
+		}
+	}
+	return nil
+}
+
+// answerOutbound records the fate of one of our requests, reporting
+// whether routeId was ours
+func (a *App) answerOutbound(routeId, status string) bool {
+	req := a.findOutbound(routeId)
+	if req == nil {
+		return false
+	}
+	if req.status == outboundAwaiting {
+		req.status = status
+		req.answeredAt = a.now()
+	}
+	return true
+}
+
+// pruneOutbound forgets answered requests once they've been seen a while
+func (a *App) pruneOutbound() {
+	kept := a.outboundRequests[:0]
+	for _, req := range a.outboundRequests {
+		if req.status == outboundAwaiting || a.now().Sub(req.answeredAt) < outboundShownFor {
+			kept = append(kept, req)
+		}
+	}
+	a.outboundRequests = kept
+	a.clampRouteCursor()
+}
+
+// requestRoute sends a route request and tracks it until it's answered
+func (a *App) requestRoute(to string) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		ack, err := client.RequestRouteContext(context.Background(), to)
+		return sendResultMsg{
+			action:  "request route to " + to,
+			confirm: "Route request sent to " + to,
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, ack api.AckEvent) tea.Cmd {
+				if ack.RouteId != "" && a.findOutbound(ack.RouteId) == nil {
+					a.outboundRequests = append(a.outboundRequests, outboundRequest{
+						to:      to,
+						routeId: ack.RouteId,
+						sentAt:  a.now(),
+						status:  outboundAwaiting,
+					})
+					// A quick accept can beat the ack here
+					for _, r := range a.routes {
+						if r.Id == ack.RouteId {
+							a.answerOutbound(ack.RouteId, outboundAccepted)
+						}
+					}
+				}
+				return nil
+			},
+		}
+	}
+}
+
+// withdrawRoute takes back one of our outbound requests
+func (a *App) withdrawRoute(req outboundRequest) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		ack, err := client.WithdrawRouteContext(context.Background(), req.routeId)
+		return sendResultMsg{
+			action:  "withdraw request to " + req.to,
+			confirm: "Withdrew route request to " + req.to,
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, _ api.AckEvent) tea.Cmd {
+				a.answerOutbound(req.routeId, outboundWithdrawn)
+				return nil
+			},
+		}
+	}
+}
+
+// answerRequest accepts or rejects an inbound request
+func (a *App) answerRequest(req routeRequest, accept bool) tea.Cmd {
+	client := a.client
+	return func() tea.Msg {
+		var ack api.AckEvent
+		var err error
+		action, confirm := "accept route from "+req.from, "Accepted route from "+req.from
+		if accept {
+			ack, err = client.AcceptRouteContext(context.Background(), req.routeId)
+		} else {
+			action, confirm = "reject route from "+req.from, "Rejected route from "+req.from
+			ack, err = client.RejectRouteContext(context.Background(), req.routeId)
+		}
+		return sendResultMsg{
+			action:  action,
+			confirm: confirm,
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, _ api.AckEvent) tea.Cmd {
+				a.removeRequest(req.routeId)
+				return nil
+			},
+		}
+	}
+}
+
+// upgradeRoute asks for confirmation with the cost, then upgrades
+func (a *App) upgradeRoute(route api.RouteState) tea.Cmd {
+	if a.upgradeArmed != route.Id {
+		a.upgradeArmed = route.Id
+		a.statusMsg = fmt.Sprintf("Upgrade %s ↔ %s for %d nits (capacity %d → %d)? u to confirm",
+			route.PlayerA, route.PlayerB, a.rules.UpgradeCost, route.Capacity, a.rules.Upgrade(route.Capacity))
+		return nil
+	}
+	a.upgradeArmed = ""
+
+	client := a.client
+	return func() tea.Msg {
+		ack, err := client.UpgradeRouteContext(context.Background(), route.Id)
+		return sendResultMsg{
+			action:  "upgrade route",
+			confirm: fmt.Sprintf("Upgraded route %s ↔ %s", route.PlayerA, route.PlayerB),
+			ack:     ack,
+			err:     err,
+			onAck: func(a *App, _ api.AckEvent) tea.Cmd {
+				// The server doesn't push route state; mirror its change
+				for i := range a.routes {
+					if a.routes[i].Id == route.Id {
+						a.routes[i].Capacity = a.rules.Upgrade(a.routes[i].Capacity)
+					}
+				}
+				return nil
+			},
+		}
+	}
+}
+
+func (a *App) renderRoutesView() string {
+	var b strings.Builder
+
+	cursor := func(i int) string {
+		if i == a.routeCursor {
+			return TabActiveStyle.Render("▸")
+		}
+		return " "
+	}
+
+	if len(a.pendingRequests) > 0 {
+		b.WriteString(LabelStyle.Render("PENDING REQUESTS"))
+		b.WriteString("\n\n")
+		for i, req := range a.pendingRequests {
+			b.WriteString(fmt.Sprintf(" %s %s from %s\n", cursor(i), WarningStyle.Render("?"), req.from))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(a.outboundRequests) > 0 {
+		b.WriteString(LabelStyle.Render("OUTBOUND REQUESTS"))
+		b.WriteString("\n\n")
+		for i, req := range a.outboundRequests {
+			var status string
+			switch req.status {
+			case outboundAwaiting:
+				status = WarningStyle.Render(fmt.Sprintf("awaiting %s", formatAge(a.now().Sub(req.sentAt))))
+			case outboundAccepted:
+				status = ConnectedStyle.Render(req.status)
+			case outboundRejected:
+				status = DisconnectedStyle.Render(req.status)
+			default:
+				status = DimStyle.Render(req.status)
+			}
+			b.WriteString(fmt.Sprintf(" %s → %s  %s\n", cursor(len(a.pendingRequests)+i), req.to, status))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(LabelStyle.Render("ACTIVE ROUTES"))
+	b.WriteString("\n\n")
+
+	if len(a.routes) == 0 {
+		b.WriteString(DimStyle.Render("  No routes established"))
+		b.WriteString("\n")
+		b.WriteString(DimStyle.Render("  Press 'r' to request a route"))
+	} else {
+		for i, route := range a.routes {
+			status := ConnectedStyle.Render("●")
+			if route.Status != "active" {
+				status = WarningStyle.Render("○")
+			}
+			b.WriteString(fmt.Sprintf(" %s %s %s ↔ %s (cap: %d)\n",
+				cursor(len(a.pendingRequests)+len(a.outboundRequests)+i), status, route.PlayerA, route.PlayerB, route.Capacity))
+		}
+	}
+
+	detail := a.renderRouteDetail()
+	if detail == "" {
+		return b.String()
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, b.String(), "    ", detail)
+}
+
+// renderRouteDetail describes the selection: a request's sender, or a
+// route's length, age, capacity, POIs and upgrade cost
+func (a *App) renderRouteDetail() string {
+	if req, ok := a.selectedRequest(); ok {
+		return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left,
+			LabelStyle.Render("REQUEST"),
+			fmt.Sprintf("from %s", req.from),
+			DimStyle.Render("a: accept  x: reject"),
+		))
+	}
+
+	if req, ok := a.selectedOutbound(); ok {
+		lines := []string{
+			LabelStyle.Render("OUTBOUND REQUEST"),
+			fmt.Sprintf("to %s", req.to),
+			fmt.Sprintf("sent %s", req.sentAt.Format("15:04:05")),
+		}
+		if req.status == outboundAwaiting {
+			lines = append(lines, DimStyle.Render("w: withdraw"))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %s", req.status, req.answeredAt.Format("15:04:05")))
+		}
+		return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	route, ok := a.selectedRoute()
+	if !ok {
+		return ""
+	}
+
+	lines := []string{
+		LabelStyle.Render(fmt.Sprintf("%s ↔ %s", route.PlayerA, route.PlayerB)),
+		fmt.Sprintf("length    %s", formatDistance(geo.Distance(route.CoordsA, route.CoordsB))),
+		fmt.Sprintf("age       %s", formatAge(a.now().Sub(time.UnixMilli(route.CreatedAt)))),
+		fmt.Sprintf("capacity  %d", route.Capacity),
+		fmt.Sprintf("status    %s", route.Status),
+	}
+
+	var pois []string
+	for _, poi := range a.intersections {
+		if contains(poi.Routes, route.Id) {
+			owner := "unclaimed"
+			if poi.Controller != "" {
+				owner = poi.Controller
+			}
+			pois = append(pois, fmt.Sprintf("  ◆ %s  %s",
+				formatCoords(poi.Coordinates.Lat, poi.Coordinates.Lng), DimStyle.Render(owner)))
+		}
+	}
+	lines = append(lines, "", fmt.Sprintf("POIs      %d", len(pois)))
+	lines = append(lines, pois...)
+
+	upgrade := fmt.Sprintf("u: upgrade  %d nits → cap %d", a.rules.UpgradeCost, a.rules.Upgrade(route.Capacity))
+	upgradeStyle := DimStyle
+	if a.player != nil && a.player.Nits < a.rules.UpgradeCost {
+		upgradeStyle = DisconnectedStyle
+		upgrade += fmt.Sprintf(" (have %d)", a.player.Nits)
+	}
+	lines = append(lines, "", upgradeStyle.Render(upgrade))
+	if a.player != nil {
+		lines = append(lines, DimStyle.Render(heatPreview(a.rules, a.player.Heat, a.rules.HeatUpgrade)))
+	}
+
+	return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
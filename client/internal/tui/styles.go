@@ -7,10 +7,10 @@ import (
 // Colors - nit-themed (luminance-based)
 var (
 	// Base colors
-	ColorDim     = lipgloss.Color("#555555")
-	ColorNormal  = lipgloss.Color("#888888")
-	ColorBright  = lipgloss.Color("#CCCCCC")
-	ColorGlow    = lipgloss.Color("#FFFFFF")
+	ColorDim    = lipgloss.Color("#555555")
+	ColorNormal = lipgloss.Color("#888888")
+	ColorBright = lipgloss.Color("#CCCCCC")
+	ColorGlow   = lipgloss.Color("#FFFFFF")
 
 	// Accent colors
 	ColorAccent  = lipgloss.Color("#FFD700") // Gold for nits
@@ -25,80 +25,111 @@ var (
 	ColorBurning = lipgloss.Color("#FF00FF") // Magenta - max heat
 )
 
-// Styles
+// Styles, built from the current colors by buildStyles
 var (
+	ContainerStyle     lipgloss.Style
+	BoxStyle           lipgloss.Style
+	PanelStyle         lipgloss.Style
+	HeaderStyle        lipgloss.Style
+	LabelStyle         lipgloss.Style
+	TabStyle           lipgloss.Style
+	TabActiveStyle     lipgloss.Style
+	StatusStyle        lipgloss.Style
+	DimStyle           lipgloss.Style
+	NitStyle           lipgloss.Style
+	ConnectedStyle     lipgloss.Style
+	DisconnectedStyle  lipgloss.Style
+	WarningStyle       lipgloss.Style
+	HelpStyle          lipgloss.Style
+	PoiControlledStyle lipgloss.Style
+	PoiContestedStyle  lipgloss.Style
+	PoiUnclaimedStyle  lipgloss.Style
+)
+
+func init() {
+	buildStyles()
+}
+
+// buildStyles (re)derives every style from the color palette
+func buildStyles() {
 	// Container styles
 	ContainerStyle = lipgloss.NewStyle().
-			Padding(1, 2).
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(ColorDim)
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDim)
 
 	// Box style for dashboard panels
 	BoxStyle = lipgloss.NewStyle().
-			Padding(1, 2).
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(ColorDim).
-			Width(24)
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDim).
+		Width(24)
+
+	// Compact bordered panel for auxiliary readouts
+	PanelStyle = lipgloss.NewStyle().
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDim)
 
 	// Header style
 	HeaderStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorAccent).
-			MarginBottom(1)
+		Bold(true).
+		Foreground(ColorAccent).
+		MarginBottom(1)
 
 	// Label style for section headers
 	LabelStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorBright)
+		Bold(true).
+		Foreground(ColorBright)
 
 	// Tab styles
 	TabStyle = lipgloss.NewStyle().
-			Foreground(ColorDim)
+		Foreground(ColorDim)
 
 	TabActiveStyle = lipgloss.NewStyle().
-			Foreground(ColorAccent).
-			Bold(true)
+		Foreground(ColorAccent).
+		Bold(true)
 
 	// Status line
 	StatusStyle = lipgloss.NewStyle().
-			Foreground(ColorNormal).
-			Italic(true)
+		Foreground(ColorNormal).
+		Italic(true)
 
 	// Dim text
 	DimStyle = lipgloss.NewStyle().
-			Foreground(ColorDim)
+		Foreground(ColorDim)
 
 	// Nit count - brightness indicates wealth
 	NitStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorAccent)
+		Bold(true).
+		Foreground(ColorAccent)
 
 	// Connected indicator
 	ConnectedStyle = lipgloss.NewStyle().
-			Foreground(ColorSuccess)
+		Foreground(ColorSuccess)
 
 	DisconnectedStyle = lipgloss.NewStyle().
-			Foreground(ColorDanger)
+		Foreground(ColorDanger)
 
 	// Warning style
 	WarningStyle = lipgloss.NewStyle().
-			Foreground(ColorWarning)
+		Foreground(ColorWarning)
 
 	// Help text
 	HelpStyle = lipgloss.NewStyle().
-			Foreground(ColorDim)
+		Foreground(ColorDim)
 
 	// POI styles
 	PoiControlledStyle = lipgloss.NewStyle().
-			Foreground(ColorSuccess).
-			Bold(true)
+		Foreground(ColorSuccess).
+		Bold(true)
 
 	PoiContestedStyle = lipgloss.NewStyle().
-			Foreground(ColorWarning)
+		Foreground(ColorWarning)
 
 	PoiUnclaimedStyle = lipgloss.NewStyle().
-			Foreground(ColorDim)
-)
+		Foreground(ColorDim)
+}
 
 // NitBrightness returns a color based on nit count (more nits = brighter)
 func NitBrightness(nits int) lipgloss.Color {
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is a full color palette; luminance still tracks wealth and heat
+type Theme struct {
+	Dim, Normal, Bright, Glow        lipgloss.Color
+	Accent, Success, Warning, Danger lipgloss.Color
+	Cool, Warm, Hot, Burning         lipgloss.Color
+}
+
+// Themes available to --theme
+var Themes = map[string]Theme{
+	// The original palette, for dark terminals
+	"default": {
+		Dim: "#555555", Normal: "#888888", Bright: "#CCCCCC", Glow: "#FFFFFF",
+		Accent: "#FFD700", Success: "#00FF88", Warning: "#FFAA00", Danger: "#FF4444",
+		Cool: "#4488FF", Warm: "#FFAA00", Hot: "#FF4444", Burning: "#FF00FF",
+	},
+	// Inverted luminance ramp for light terminals
+	"light": {
+		Dim: "#AAAAAA", Normal: "#777777", Bright: "#333333", Glow: "#000000",
+		Accent: "#B8860B", Success: "#008844", Warning: "#CC7700", Danger: "#CC0000",
+		Cool: "#0055CC", Warm: "#CC7700", Hot: "#CC0000", Burning: "#AA00AA",
+	},
+	// Grayscale only, for limited or e-ink displays
+	"mono": {
+		Dim: "#555555", Normal: "#888888", Bright: "#CCCCCC", Glow: "#FFFFFF",
+		Accent: "#FFFFFF", Success: "#CCCCCC", Warning: "#FFFFFF", Danger: "#FFFFFF",
+		Cool: "#555555", Warm: "#888888", Hot: "#CCCCCC", Burning: "#FFFFFF",
+	},
+}
+
+// ThemeNames lists the available themes in sorted order
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyTheme switches the palette and rebuilds all styles; call it before
+// creating the App
+func ApplyTheme(name string) error {
+	if name == "" {
+		name = "default"
+	}
+	t, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %v)", name, ThemeNames())
+	}
+
+	ColorDim, ColorNormal, ColorBright, ColorGlow = t.Dim, t.Normal, t.Bright, t.Glow
+	ColorAccent, ColorSuccess, ColorWarning, ColorDanger = t.Accent, t.Success, t.Warning, t.Danger
+	ColorCool, ColorWarm, ColorHot, ColorBurning = t.Cool, t.Warm, t.Hot, t.Burning
+
+	buildStyles()
+	return nil
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/philip/foam/internal/api"
+	"github.com/philip/foam/internal/eventlog"
+	"github.com/philip/foam/internal/geo"
+)
+
+// visibilityHighlight is how long an enter/leave stays highlighted
+const visibilityHighlight = 30 * time.Second
+
+// visibilityChange marks a player who recently entered or left our view
+type visibilityChange struct {
+	entered bool
+	at      time.Time
+	player  api.VisiblePlayer // Last known state, kept to show who left
+}
+
+// updateVisibility replaces the visible set and records who came and went
+func (a *App) updateVisibility(players []api.VisiblePlayer) {
+	now := a.now()
+	if a.visibilityChanges == nil {
+		a.visibilityChanges = make(map[string]visibilityChange)
+	}
+
+	seen := make(map[string]bool, len(players))
+	var entered, left []string
+	for _, p := range players {
+		seen[p.Username] = true
+		if _, ok := a.visiblePlayers[p.Username]; !ok && a.visibilityKnown {
+			entered = append(entered, p.Username)
+			a.visibilityChanges[p.Username] = visibilityChange{entered: true, at: now, player: p}
+		}
+	}
+	for name, p := range a.visiblePlayers {
+		if !seen[name] {
+			left = append(left, name)
+			a.visibilityChanges[name] = visibilityChange{at: now, player: p}
+		}
+	}
+
+	a.visiblePlayers = make(map[string]api.VisiblePlayer, len(players))
+	for _, p := range players {
+		a.visiblePlayers[p.Username] = p
+	}
+	a.visibilityKnown = true
+
+	// Forget old highlights
+	for name, ch := range a.visibilityChanges {
+		if now.Sub(ch.at) > visibilityHighlight {
+			delete(a.visibilityChanges, name)
+		}
+	}
+
+	sort.Strings(entered)
+	sort.Strings(left)
+	var parts []string
+	if len(entered) > 0 {
+		parts = append(parts, strings.Join(entered, ", ")+" entered view")
+	}
+	if len(left) > 0 {
+		parts = append(parts, strings.Join(left, ", ")+" left view")
+	}
+	if len(parts) > 0 {
+		a.logEvent(eventlog.Info, eventlog.KindVisibility, "%s", strings.Join(parts, "; "))
+	}
+}
+
+// renderVisiblePanel lists visible players, nearest first, with anyone who
+// recently left shown struck through until the highlight fades
+func (a *App) renderVisiblePanel() string {
+	now := a.now()
+
+	type row struct {
+		p      api.VisiblePlayer
+		change *visibilityChange
+		dist   float64
+	}
+	var rows []row
+	for name, p := range a.visiblePlayers {
+		r := row{p: p}
+		if ch, ok := a.visibilityChanges[name]; ok && ch.entered && now.Sub(ch.at) <= visibilityHighlight {
+			r.change = &ch
+		}
+		rows = append(rows, r)
+	}
+	for name, ch := range a.visibilityChanges {
+		if _, ok := a.visiblePlayers[name]; !ok && now.Sub(ch.at) <= visibilityHighlight {
+			ch := ch
+			rows = append(rows, row{p: ch.player, change: &ch})
+		}
+	}
+
+	if a.player != nil {
+		for i := range rows {
+			rows[i].dist = geo.Distance(a.player.Coordinates, rows[i].p.Coordinates)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].dist != rows[j].dist {
+			return rows[i].dist < rows[j].dist
+		}
+		return rows[i].p.Username < rows[j].p.Username
+	})
+
+	lines := []string{LabelStyle.Render("VISIBLE PLAYERS"), ""}
+	if len(rows) == 0 {
+		lines = append(lines, DimStyle.Render("  Nobody in view"))
+	}
+	for _, r := range rows {
+		heatStyle := lipgloss.NewStyle().Foreground(HeatColor(r.p.Heat))
+
+		marker, name := " ", fmt.Sprintf("%-7s", r.p.Username)
+		if r.change != nil {
+			if r.change.entered {
+				marker = WarningStyle.Render("+")
+				name = WarningStyle.Render(name)
+			} else {
+				marker = DimStyle.Render("-")
+				name = DimStyle.Strikethrough(true).Render(name)
+			}
+		}
+
+		nits := ""
+		if r.p.Nits != nil {
+			nits = "  " + NitStyle.Render(fmt.Sprintf("%d nits", *r.p.Nits))
+		}
+
+		lines = append(lines, fmt.Sprintf(" %s %s %s %s  %s%s",
+			marker, name,
+			heatStyle.Render(HeatBar(r.p.Heat)), heatStyle.Render(fmt.Sprintf("%3d%%", r.p.Heat)),
+			DimStyle.Render(formatDistance(r.dist)), nits))
+	}
+
+	return PanelStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func formatDistance(km float64) string {
+	if km < 10 {
+		return fmt.Sprintf("%.1f km", km)
+	}
+	return fmt.Sprintf("%.0f km", km)
+}
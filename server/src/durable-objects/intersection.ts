@@ -103,6 +103,18 @@ export class IntersectionDO implements DurableObject {
       }
 
       await this.state.storage.put('intersection', this.intersection);
+
+      // Warn the controller that someone is buying into their POI
+      if (previousController && previousController !== player) {
+        const defenderId = this.env.PLAYER.idFromName(previousController);
+        const defenderDO = this.env.PLAYER.get(defenderId);
+        await defenderDO.fetch(new Request('http://internal/poi-contested', {
+          method: 'POST',
+          body: JSON.stringify({ attacker: player, amount, poi: this.intersection }),
+          headers: { 'Content-Type': 'application/json' },
+        }));
+      }
+
       return Response.json(this.intersection);
     }
 
@@ -196,5 +196,12 @@ export class MarketDO implements DurableObject {
       body: JSON.stringify({ amount: 2, reason: `market ${side}` }),
       headers: { 'Content-Type': 'application/json' },
     }));
+
+    // Tell the player's clients which order filled
+    await playerDO.fetch(new Request('http://internal/order-filled', {
+      method: 'POST',
+      body: JSON.stringify({ orderId, amount, price, side: side === 'bought' ? 'bid' : 'ask' }),
+      headers: { 'Content-Type': 'application/json' },
+    }));
   }
 }
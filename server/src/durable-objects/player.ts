@@ -1,5 +1,6 @@
-import { PlayerState, ServerMessage, ClientMessage, RouteState, IntersectionState, Env, VisiblePlayer } from '../types';
+import { PlayerState, ServerMessage, ClientMessage, RouteState, IntersectionState, Env, VisiblePlayer, AckDetails, Credentials, SessionGrant } from '../types';
 import { getLocationFromRequest, getRegionFromRequest, randomizeWithinNeighborhood } from '../lib/geo';
+import { SESSION_TTL, generateToken, hashToken, bearerToken } from '../lib/auth';
 
 // Heat constants
 const HEAT_DECAY_PER_TICK = 1;
@@ -23,8 +24,11 @@ export class PlayerDO implements DurableObject {
   private sessions: Set<WebSocket> = new Set();
   private player: PlayerState | null = null;
   private pendingRouteRequests: Map<string, { from: string; routeId: string }> = new Map();
+  private outboundRouteRequests: Map<string, { to: string; routeId: string }> = new Map(); // Requests we sent, awaiting an answer
   private lastRequest: Request | null = null;
   private controlledPois: string[] = []; // POI IDs this player controls
+  private credentials: Credentials | null = null; // Set once the username is claimed
+  private authedSessions: WeakSet<WebSocket> = new WeakSet(); // Sessions that presented a valid token
 
   constructor(state: DurableObjectState, env: Env) {
     this.state = state;
@@ -45,12 +49,35 @@ export class PlayerDO implements DurableObject {
       return this.getState();
     }
 
+    // Claim this username (called by /auth/claim)
+    if (url.pathname === '/claim' && request.method === 'POST') {
+      return this.handleClaim();
+    }
+
+    // Refresh the session token (called by /auth/refresh)
+    if (url.pathname === '/refresh' && request.method === 'POST') {
+      const body = await request.json() as { refreshToken?: string };
+      return this.handleRefresh(body.refreshToken);
+    }
+
     // Route request notification (called by another player's DO)
     if (url.pathname === '/route-request' && request.method === 'POST') {
       const body = await request.json() as { from: string; routeId: string };
       return this.handleRouteRequestNotification(body.from, body.routeId);
     }
 
+    // Route rejected notification (our outbound request was turned down)
+    if (url.pathname === '/route-rejected' && request.method === 'POST') {
+      const body = await request.json() as { routeId: string };
+      return this.handleRouteRejectedNotification(body.routeId);
+    }
+
+    // Route withdrawn notification (the requester took back their request)
+    if (url.pathname === '/route-withdrawn' && request.method === 'POST') {
+      const body = await request.json() as { routeId: string };
+      return this.handleRouteWithdrawnNotification(body.routeId);
+    }
+
     // Route accepted notification
     if (url.pathname === '/route-accepted' && request.method === 'POST') {
       const body = await request.json() as { routeId: string; route: RouteState };
@@ -63,6 +90,13 @@ export class PlayerDO implements DurableObject {
       return this.handleUpdateNits(body.delta, body.reason);
     }
 
+    // Order filled (called by market)
+    if (url.pathname === '/order-filled' && request.method === 'POST') {
+      const body = await request.json() as { orderId: string; amount: number; price: number; side: 'bid' | 'ask' };
+      this.broadcast({ type: 'order_filled', ...body });
+      return new Response('OK');
+    }
+
     // Add heat (called by various systems)
     if (url.pathname === '/add-heat' && request.method === 'POST') {
       const body = await request.json() as { amount: number; reason: string };
@@ -75,6 +109,20 @@ export class PlayerDO implements DurableObject {
       return this.handlePoiControlChanged(body.poiId, body.isController);
     }
 
+    // Someone invested in a POI we control
+    if (url.pathname === '/poi-contested' && request.method === 'POST') {
+      const body = await request.json() as { attacker: string; amount: number; poi: IntersectionState };
+      this.broadcast({
+        type: 'poi_contest',
+        poiId: body.poi.id,
+        attacker: body.attacker,
+        amount: body.amount,
+        newController: body.poi.controller,
+      });
+      this.broadcast({ type: 'poi_update', poi: body.poi });
+      return new Response('OK');
+    }
+
     // Toll received from POI
     if (url.pathname === '/toll-received' && request.method === 'POST') {
       const body = await request.json() as { amount: number; fromPoi: string };
@@ -95,6 +143,11 @@ export class PlayerDO implements DurableObject {
     // Intersection created notification
     if (url.pathname === '/intersection-created' && request.method === 'POST') {
       const intersection = await request.json() as IntersectionState;
+      const known = await this.state.storage.get<string[]>('knownPois') ?? [];
+      if (!known.includes(intersection.id)) {
+        known.push(intersection.id);
+        await this.state.storage.put('knownPois', known);
+      }
       this.broadcast({ type: 'intersection_created', intersection });
       return new Response('OK');
     }
@@ -228,7 +281,13 @@ export class PlayerDO implements DurableObject {
       this.pendingRouteRequests = new Map(storedRequests);
     }
 
-    if (this.player) {
+    // A valid token on the handshake authenticates the session up front
+    this.credentials = await this.state.storage.get<Credentials>('credentials') ?? null;
+    if (this.credentials && await this.checkToken(bearerToken(request)) === 'ok') {
+      this.authedSessions.add(server);
+    }
+
+    if (this.player && this.isAuthorized(server)) {
       this.send(server, { type: 'connected', username: this.player.username });
       this.send(server, { type: 'state', player: this.player });
     }
@@ -236,15 +295,146 @@ export class PlayerDO implements DurableObject {
     return new Response(null, { status: 101, webSocket: client });
   }
 
+  private async handleClaim(): Promise<Response> {
+    this.credentials = await this.state.storage.get<Credentials>('credentials') ?? null;
+    if (this.credentials) {
+      return new Response('Username already claimed', { status: 409 });
+    }
+    // Anyone could otherwise take over a player that is already playing;
+    // it claims over its own connection instead (see handleClaimMessage)
+    if (await this.state.storage.get<PlayerState>('player')) {
+      return new Response('Player exists; claim from a connected session', { status: 403 });
+    }
+
+    const grant = await this.issueSession(generateToken());
+    return Response.json(grant);
+  }
+
+  // An existing, unclaimed player claims its username over a live session,
+  // which is the proof that it is the one playing. Only the claiming socket
+  // stays authorized; the others must authenticate with the new token.
+  private async handleClaimMessage(ws: WebSocket, requestId?: string): Promise<void> {
+    if (!this.player) {
+      this.send(ws, { type: 'error', message: 'Not authenticated', requestId });
+      return;
+    }
+    if (this.credentials) {
+      this.send(ws, { type: 'error', message: 'Username already claimed', requestId });
+      return;
+    }
+
+    const session = await this.issueSession(generateToken());
+    this.authedSessions.add(ws);
+    for (const other of this.sessions) {
+      if (other !== ws) {
+        this.send(other, { type: 'error', message: 'Username was claimed by another session', code: 'auth_required' });
+      }
+    }
+    this.send(ws, { type: 'ack', requestId: requestId ?? '', session });
+  }
+
+  private async handleRefresh(refreshToken: string | undefined): Promise<Response> {
+    this.credentials = await this.state.storage.get<Credentials>('credentials') ?? null;
+    if (!this.credentials || !refreshToken || await hashToken(refreshToken) !== this.credentials.refreshHash) {
+      return new Response('Invalid refresh token', { status: 401 });
+    }
+
+    const grant = await this.issueSession(refreshToken);
+    return Response.json(grant);
+  }
+
+  // Store a fresh session token alongside the given refresh token
+  private async issueSession(refreshToken: string): Promise<SessionGrant> {
+    const token = generateToken();
+    const expiresAt = Date.now() + SESSION_TTL;
+
+    this.credentials = {
+      tokenHash: await hashToken(token),
+      refreshHash: await hashToken(refreshToken),
+      expiresAt,
+    };
+    await this.state.storage.put('credentials', this.credentials);
+
+    const player = await this.state.storage.get<PlayerState>('player');
+    return {
+      username: player?.username ?? '',
+      token,
+      refreshToken,
+      expiresAt,
+    };
+  }
+
+  private async checkToken(token: string | undefined): Promise<'ok' | 'expired' | 'invalid'> {
+    if (!this.credentials || !token) return 'invalid';
+    if (await hashToken(token) !== this.credentials.tokenHash) return 'invalid';
+    if (Date.now() > this.credentials.expiresAt) return 'expired';
+    return 'ok';
+  }
+
+  // Unclaimed usernames (and bots) stay open, as before claiming existed.
+  // A claimed session stops being authorized once its token expires.
+  private isAuthorized(ws: WebSocket): boolean {
+    if (!this.credentials) return true;
+    return this.authedSessions.has(ws) && Date.now() <= this.credentials.expiresAt;
+  }
+
+  // Demote sessions whose token has expired; the client refreshes and
+  // re-authenticates on the same socket
+  private expireSessions(): void {
+    if (!this.credentials || Date.now() <= this.credentials.expiresAt) return;
+    for (const ws of this.sessions) {
+      if (this.authedSessions.delete(ws)) {
+        this.send(ws, { type: 'error', message: 'Session expired', code: 'auth_expired' });
+      }
+    }
+  }
+
   async webSocketMessage(ws: WebSocket, message: string | ArrayBuffer): Promise<void> {
     if (typeof message !== 'string') return;
 
+    let requestId: string | undefined;
     try {
       const msg: ClientMessage = JSON.parse(message);
-      await this.handleMessage(ws, msg);
+      requestId = msg.requestId;
+
+      if (msg.type !== 'auth' && msg.type !== 'ping' && !this.isAuthorized(ws)) {
+        this.send(ws, { type: 'error', message: 'Not authenticated', code: 'auth_required', requestId });
+        return;
+      }
+
+      // claim authorizes the real socket, so it isn't wrapped below either
+      if (msg.type === 'claim') {
+        await this.handleClaimMessage(ws, requestId);
+        return;
+      }
+
+      // auth registers the real socket, so it is never wrapped below
+      if (!requestId || msg.type === 'auth') {
+        await this.handleMessage(ws, msg);
+        return;
+      }
+
+      // Tag any error the handler sends with this request's id, and ack
+      // the request if it completed without one
+      let failed = false;
+      const scoped = {
+        send: (data: string) => {
+          const out = JSON.parse(data) as ServerMessage;
+          if (out.type === 'error') {
+            failed = true;
+            out.requestId = requestId;
+          }
+          ws.send(JSON.stringify(out));
+        },
+      } as unknown as WebSocket;
+
+      const details = await this.handleMessage(scoped, msg);
+      if (!failed) {
+        this.send(ws, { type: 'ack', requestId, ...details });
+      }
     } catch (e) {
       const errorMsg = e instanceof Error ? e.message : 'Invalid message format';
-      this.send(ws, { type: 'error', message: errorMsg });
+      this.send(ws, { type: 'error', message: errorMsg, requestId });
     }
   }
 
@@ -256,25 +446,27 @@ export class PlayerDO implements DurableObject {
     this.sessions.delete(ws);
   }
 
-  private async handleMessage(ws: WebSocket, msg: ClientMessage): Promise<void> {
+  private async handleMessage(ws: WebSocket, msg: ClientMessage): Promise<AckDetails> {
     switch (msg.type) {
       case 'auth':
-        await this.handleAuth(ws, msg.username);
+        await this.handleAuth(ws, msg.username, msg.token);
         break;
       case 'ping':
+        this.send(ws, { type: 'pong' });
         break;
       case 'request_route':
-        await this.handleRequestRoute(ws, msg.to);
-        break;
+        return (await this.handleRequestRoute(ws, msg.to)) ?? {};
       case 'accept_route':
         await this.handleAcceptRoute(ws, msg.routeId);
         break;
       case 'reject_route':
         await this.handleRejectRoute(ws, msg.routeId);
         break;
-      case 'place_order':
-        await this.handlePlaceOrder(ws, msg.side, msg.price, msg.amount);
+      case 'withdraw_route':
+        await this.handleWithdrawRoute(ws, msg.routeId);
         break;
+      case 'place_order':
+        return (await this.handlePlaceOrder(ws, msg.side, msg.price, msg.amount)) ?? {};
       case 'cancel_order':
         await this.handleCancelOrder(ws, msg.orderId);
         break;
@@ -285,14 +477,29 @@ export class PlayerDO implements DurableObject {
         await this.handleUpgradeRoute(ws, msg.routeId);
         break;
     }
+    return {};
   }
 
-  private async handleAuth(ws: WebSocket, username: string): Promise<void> {
+  private async handleAuth(ws: WebSocket, username: string, token?: string): Promise<void> {
     if (!/^[a-zA-Z0-9]{1,7}$/.test(username)) {
       this.send(ws, { type: 'error', message: 'Username must be 1-7 alphanumeric characters' });
       return;
     }
 
+    // Claimed usernames need a valid session token, here or on the handshake
+    if (this.credentials && !this.authedSessions.has(ws)) {
+      const result = await this.checkToken(token);
+      if (result === 'expired') {
+        this.send(ws, { type: 'error', message: 'Session expired', code: 'auth_expired' });
+        return;
+      }
+      if (result === 'invalid') {
+        this.send(ws, { type: 'error', message: 'Username is claimed; a valid token is required', code: 'auth_required' });
+        return;
+      }
+      this.authedSessions.add(ws);
+    }
+
     if (!this.player) {
       // Get location from request (Cloudflare cf object)
       const baseCoords = this.lastRequest
@@ -325,9 +532,51 @@ export class PlayerDO implements DurableObject {
 
     this.send(ws, { type: 'connected', username: this.player.username });
     this.send(ws, { type: 'state', player: this.player });
+    await this.sendSnapshot(ws);
   }
 
-  private async handleRequestRoute(ws: WebSocket, toUsername: string): Promise<void> {
+  // Send our routes, pending route requests both ways and known POIs, so
+  // a fresh client starts complete. 'pois' comes last and marks the end of
+  // the snapshot.
+  private async sendSnapshot(ws: WebSocket): Promise<void> {
+    if (!this.player) return;
+
+    const routes: RouteState[] = [];
+    for (const routeId of this.player.routes) {
+      const routeDO = this.env.ROUTE.get(this.env.ROUTE.idFromName(routeId));
+      const resp = await routeDO.fetch(new Request('http://internal/state'));
+      if (resp.ok) {
+        routes.push(await resp.json() as RouteState);
+      }
+    }
+    if (this.pendingRouteRequests.size === 0) {
+      const storedRequests = await this.state.storage.get<[string, { from: string; routeId: string }][]>('pendingRequests');
+      if (storedRequests) {
+        this.pendingRouteRequests = new Map(storedRequests);
+      }
+    }
+    await this.loadOutboundRequests();
+    this.send(ws, {
+      type: 'routes',
+      routes,
+      requests: Array.from(this.pendingRouteRequests.values()),
+      outbound: Array.from(this.outboundRouteRequests.values()),
+    });
+
+    const known = await this.state.storage.get<string[]>('knownPois') ?? [];
+    const poiIds = new Set([...known, ...Object.keys(this.player.poiInvestments || {})]);
+    const pois: IntersectionState[] = [];
+    for (const poiId of poiIds) {
+      const poiDO = this.env.INTERSECTION.get(this.env.INTERSECTION.idFromName(poiId));
+      const resp = await poiDO.fetch(new Request('http://internal/state'));
+      if (resp.ok) {
+        pois.push(await resp.json() as IntersectionState);
+      }
+    }
+    this.send(ws, { type: 'pois', pois });
+  }
+
+  private async handleRequestRoute(ws: WebSocket, toUsername: string): Promise<AckDetails | undefined> {
     if (!this.player) {
       this.send(ws, { type: 'error', message: 'Not authenticated' });
       return;
@@ -363,8 +612,11 @@ export class PlayerDO implements DurableObject {
         return;
       }
 
-      // Store pending request on our side
-      this.pendingRouteRequests.set(routeId, { from: this.player.username, routeId });
+      // Track the request on our side until it's answered
+      await this.loadOutboundRequests();
+      this.outboundRouteRequests.set(routeId, { to: targetUsername, routeId });
+      await this.saveOutboundRequests();
+      return { routeId };
     } catch (e) {
       this.send(ws, { type: 'error', message: 'Target player not found' });
     }
@@ -470,7 +722,9 @@ export class PlayerDO implements DurableObject {
       await this.state.storage.put('player', this.player);
     }
 
-    this.pendingRouteRequests.delete(routeId);
+    await this.loadOutboundRequests();
+    this.outboundRouteRequests.delete(routeId);
+    await this.saveOutboundRequests();
     this.broadcast({ type: 'route_accepted', routeId, route });
 
     return new Response('OK');
@@ -486,10 +740,76 @@ export class PlayerDO implements DurableObject {
     this.pendingRouteRequests.delete(routeId);
     await this.state.storage.put('pendingRequests', Array.from(this.pendingRouteRequests.entries()));
 
-    // Could notify the requester, but for now just remove locally
+    // Tell the requester so their request doesn't hang forever
+    const fromDO = this.env.PLAYER.get(this.env.PLAYER.idFromName(request.from));
+    await fromDO.fetch(new Request('http://internal/route-rejected', {
+      method: 'POST',
+      body: JSON.stringify({ routeId }),
+      headers: { 'Content-Type': 'application/json' },
+    }));
+
     this.broadcast({ type: 'route_rejected', routeId });
   }
 
+  private async handleWithdrawRoute(ws: WebSocket, routeId: string): Promise<void> {
+    await this.loadOutboundRequests();
+    const request = this.outboundRouteRequests.get(routeId);
+    if (!request) {
+      this.send(ws, { type: 'error', message: 'Route request not found' });
+      return;
+    }
+
+    this.outboundRouteRequests.delete(routeId);
+    await this.saveOutboundRequests();
+
+    const targetDO = this.env.PLAYER.get(this.env.PLAYER.idFromName(request.to));
+    await targetDO.fetch(new Request('http://internal/route-withdrawn', {
+      method: 'POST',
+      body: JSON.stringify({ routeId }),
+      headers: { 'Content-Type': 'application/json' },
+    }));
+
+    this.broadcast({ type: 'route_withdrawn', routeId });
+  }
+
+  private async handleRouteRejectedNotification(routeId: string): Promise<Response> {
+    await this.loadOutboundRequests();
+    this.outboundRouteRequests.delete(routeId);
+    await this.saveOutboundRequests();
+
+    this.broadcast({ type: 'route_rejected', routeId });
+    return new Response('OK');
+  }
+
+  private async handleRouteWithdrawnNotification(routeId: string): Promise<Response> {
+    // Load pending requests if not already loaded (REST call, no WebSocket)
+    if (this.pendingRouteRequests.size === 0) {
+      const storedRequests = await this.state.storage.get<[string, { from: string; routeId: string }][]>('pendingRequests');
+      if (storedRequests) {
+        this.pendingRouteRequests = new Map(storedRequests);
+      }
+    }
+
+    if (this.pendingRouteRequests.delete(routeId)) {
+      await this.state.storage.put('pendingRequests', Array.from(this.pendingRouteRequests.entries()));
+      this.broadcast({ type: 'route_withdrawn', routeId });
+    }
+    return new Response('OK');
+  }
+
+  private async loadOutboundRequests(): Promise<void> {
+    if (this.outboundRouteRequests.size === 0) {
+      const stored = await this.state.storage.get<[string, { to: string; routeId: string }][]>('outboundRequests');
+      if (stored) {
+        this.outboundRouteRequests = new Map(stored);
+      }
+    }
+  }
+
+  private async saveOutboundRequests(): Promise<void> {
+    await this.state.storage.put('outboundRequests', Array.from(this.outboundRouteRequests.entries()));
+  }
+
   private async checkForIntersections(newRoute: RouteState): Promise<void> {
     // Get all existing routes from ALL players we know about
     // This is necessary because intersections can happen between routes
@@ -700,7 +1020,7 @@ export class PlayerDO implements DurableObject {
     this.broadcast({ type: 'state', player: this.player });
   }
 
-  private async handlePlaceOrder(ws: WebSocket, side: 'bid' | 'ask', price: number, amount: number): Promise<void> {
+  private async handlePlaceOrder(ws: WebSocket, side: 'bid' | 'ask', price: number, amount: number): Promise<AckDetails | undefined> {
     if (!this.player) {
       this.send(ws, { type: 'error', message: 'Not authenticated' });
       return;
@@ -739,6 +1059,8 @@ export class PlayerDO implements DurableObject {
     this.player.heat = Math.min(HEAT_MAX, this.player.heat + HEAT_TRADE);
     await this.state.storage.put('player', this.player);
     this.broadcast({ type: 'heat_update', heat: this.player.heat });
+
+    return { orderId };
   }
 
   private async handleCancelOrder(ws: WebSocket, orderId: string): Promise<void> {
@@ -750,11 +1072,16 @@ export class PlayerDO implements DurableObject {
     const marketId = this.env.MARKET.idFromName('global');
     const marketDO = this.env.MARKET.get(marketId);
 
-    await marketDO.fetch(new Request('http://internal/cancel-order', {
+    const resp = await marketDO.fetch(new Request('http://internal/cancel-order', {
       method: 'POST',
       body: JSON.stringify({ orderId, player: this.player.username }),
       headers: { 'Content-Type': 'application/json' },
     }));
+
+    // Already filled, already cancelled, or not ours
+    if (!resp.ok) {
+      this.send(ws, { type: 'error', message: 'Order not found' });
+    }
   }
 
   private async handleUpdateNits(delta: number, reason: string): Promise<Response> {
@@ -867,6 +1194,8 @@ export class PlayerDO implements DurableObject {
 
     await this.state.storage.put('player', this.player);
 
+    this.credentials = await this.state.storage.get<Credentials>('credentials') ?? null;
+    this.expireSessions();
     this.broadcast({ type: 'tick', nits: this.player.nits, heat: this.player.heat });
 
     await this.state.storage.setAlarm(Date.now() + TICK_INTERVAL);
@@ -888,9 +1217,13 @@ export class PlayerDO implements DurableObject {
     }
   }
 
+  // Only authorized sessions see the player's stream; a socket that has
+  // not authenticated a claimed username gets nothing but its auth error
   private broadcast(msg: ServerMessage): void {
     for (const ws of this.sessions) {
-      this.send(ws, msg);
+      if (this.isAuthorized(ws)) {
+        this.send(ws, msg);
+      }
     }
   }
 
@@ -15,7 +15,7 @@ export default {
     const corsHeaders = {
       'Access-Control-Allow-Origin': '*',
       'Access-Control-Allow-Methods': 'GET, POST, OPTIONS',
-      'Access-Control-Allow-Headers': 'Content-Type',
+      'Access-Control-Allow-Headers': 'Content-Type, Authorization',
     };
 
     if (request.method === 'OPTIONS') {
@@ -56,6 +56,30 @@ export default {
       return Response.json(status, { headers: corsHeaders });
     }
 
+    // Claim a username or refresh its session: /auth/claim, /auth/refresh
+    if ((url.pathname === '/auth/claim' || url.pathname === '/auth/refresh') && request.method === 'POST') {
+      const body = await request.json() as { username?: string; refreshToken?: string };
+      const username = body.username ?? '';
+      if (!/^[a-zA-Z0-9]{1,7}$/.test(username)) {
+        return new Response('Invalid username', { status: 400, headers: corsHeaders });
+      }
+
+      const id = env.PLAYER.idFromName(username.toLowerCase());
+      const player = env.PLAYER.get(id);
+
+      const action = url.pathname === '/auth/claim' ? 'claim' : 'refresh';
+      const resp = await player.fetch(new Request(`http://internal/${action}`, {
+        method: 'POST',
+        body: JSON.stringify({ username, refreshToken: body.refreshToken }),
+        headers: { 'Content-Type': 'application/json' },
+      }));
+
+      return new Response(resp.body, {
+        status: resp.status,
+        headers: { ...corsHeaders, 'Content-Type': resp.headers.get('Content-Type') ?? 'text/plain' },
+      });
+    }
+
     // Market state
     if (url.pathname === '/market') {
       const marketId = env.MARKET.idFromName('global');
@@ -0,0 +1,24 @@
+// Session token helpers for username claims
+
+// Session tokens expire after a day; the refresh token lasts until revoked
+export const SESSION_TTL = 24 * 60 * 60 * 1000;
+
+// Generate an unguessable token
+export function generateToken(): string {
+  const bytes = new Uint8Array(32);
+  crypto.getRandomValues(bytes);
+  return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
+// Hash a token for storage so a leaked snapshot can't be replayed
+export async function hashToken(token: string): Promise<string> {
+  const digest = await crypto.subtle.digest('SHA-256', new TextEncoder().encode(token));
+  return Array.from(new Uint8Array(digest), b => b.toString(16).padStart(2, '0')).join('');
+}
+
+// Extract a bearer token from an Authorization header
+export function bearerToken(request: Request): string | undefined {
+  const header = request.headers.get('Authorization');
+  if (!header?.startsWith('Bearer ')) return undefined;
+  return header.slice('Bearer '.length).trim() || undefined;
+}
@@ -49,30 +49,64 @@ export type ServerMessage =
   | { type: 'connected'; username: string }
   | { type: 'state'; player: PlayerState }
   | { type: 'tick'; nits: number; heat: number }
-  | { type: 'error'; message: string }
+  | { type: 'error'; message: string; requestId?: string; code?: AuthErrorCode }
+  | { type: 'ack'; requestId: string } & AckDetails
   | { type: 'route_request'; from: string; routeId: string }
   | { type: 'route_accepted'; routeId: string; route: RouteState }
   | { type: 'route_rejected'; routeId: string }
-  | { type: 'routes'; routes: RouteState[] }
+  | { type: 'route_withdrawn'; routeId: string }
+  | { type: 'routes'; routes: RouteState[]; requests: { from: string; routeId: string }[]; outbound: { to: string; routeId: string }[] }
+  | { type: 'pois'; pois: IntersectionState[] }
   | { type: 'intersection_created'; intersection: IntersectionState }
   | { type: 'market_update'; bids: MarketOrder[]; asks: MarketOrder[] }
-  | { type: 'order_filled'; orderId: string; amount: number; price: number }
+  | { type: 'order_filled'; orderId: string; amount: number; price: number; side: 'bid' | 'ask' }
   | { type: 'heat_update'; heat: number }
   | { type: 'poi_update'; poi: IntersectionState }
   | { type: 'poi_contest'; poiId: string; attacker: string; amount: number; newController: string | null }
   | { type: 'toll_received'; amount: number; fromPoi: string }
-  | { type: 'visibility_update'; visiblePlayers: VisiblePlayer[] };
+  | { type: 'visibility_update'; visiblePlayers: VisiblePlayer[] }
+  | { type: 'pong' };
 
-export type ClientMessage =
-  | { type: 'auth'; username: string }
+// Error codes telling the client to (re)authenticate
+export type AuthErrorCode = 'auth_required' | 'auth_expired';
+
+// Stored credentials for a claimed username (hashes only)
+export interface Credentials {
+  tokenHash: string;
+  refreshHash: string;
+  expiresAt: number;
+}
+
+// Returned by /auth/claim and /auth/refresh
+export interface SessionGrant {
+  username: string;
+  token: string;
+  refreshToken: string;
+  expiresAt: number;
+}
+
+// Extra fields returned in an ack for actions that create something
+export interface AckDetails {
+  orderId?: string;
+  routeId?: string;
+  session?: SessionGrant; // claim
+}
+
+// Any client message may carry a requestId; the server answers it with
+// either an 'ack' or an 'error' echoing the same id
+export type ClientMessage = (
+  | { type: 'auth'; username: string; token?: string }
   | { type: 'ping' }
+  | { type: 'claim' }
   | { type: 'request_route'; to: string }
   | { type: 'accept_route'; routeId: string }
   | { type: 'reject_route'; routeId: string }
+  | { type: 'withdraw_route'; routeId: string }
   | { type: 'place_order'; side: 'bid' | 'ask'; price: number; amount: number }
   | { type: 'cancel_order'; orderId: string }
   | { type: 'invest_poi'; poiId: string; amount: number }
-  | { type: 'upgrade_route'; routeId: string };
+  | { type: 'upgrade_route'; routeId: string }
+) & { requestId?: string };
 
 // Visible player info for fog of war
 export interface VisiblePlayer {
@@ -0,0 +1,121 @@
+// End-to-end test for claimed usernames: an unauthenticated socket must not
+// see the owner's stream, and an existing player can only claim over its
+// own connection
+const WebSocket = require('ws');
+
+const SERVER_URL = 'ws://localhost:8787';
+const HTTP_URL = 'http://localhost:8787';
+const TICK_WAIT = 12000; // One production tick (10s) plus slack
+
+function createClient(username, token) {
+  return new Promise((resolve, reject) => {
+    const headers = token ? { Authorization: `Bearer ${token}` } : {};
+    const ws = new WebSocket(`${SERVER_URL}/ws/${username}`, { headers });
+    const messages = [];
+
+    ws.on('open', () => {
+      ws.send(JSON.stringify({ type: 'auth', username, token }));
+    });
+
+    ws.on('message', (data) => {
+      const msg = JSON.parse(data.toString());
+      messages.push(msg);
+      console.log(`[${username}${token ? '' : ' anon'}] received:`, msg.type, msg.message || '');
+    });
+
+    ws.on('error', reject);
+
+    setTimeout(() => {
+      resolve({ ws, messages, username });
+    }, 500);
+  });
+}
+
+function waitForMessage(client, type, timeout = 5000) {
+  return new Promise((resolve, reject) => {
+    const start = Date.now();
+    const check = () => {
+      const found = client.messages.find(m => m.type === type);
+      if (found) return resolve(found);
+      if (Date.now() - start > timeout) return reject(new Error(`Timeout waiting for ${type}`));
+      setTimeout(check, 100);
+    };
+    check();
+  });
+}
+
+async function claim(username) {
+  const resp = await fetch(`${HTTP_URL}/auth/claim`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username }),
+  });
+  return { status: resp.status, grant: resp.ok ? await resp.json() : null };
+}
+
+async function test() {
+  console.log('=== Auth Test ===\n');
+  const failures = [];
+
+  const ts = Date.now().toString().slice(-4);
+  const owner = `tstc${ts.slice(0, 3)}`;
+  const legacy = `tstd${ts.slice(0, 3)}`;
+
+  // 1. A fresh username can be claimed over HTTP
+  const { status, grant } = await claim(owner);
+  if (status !== 200 || !grant?.token) {
+    throw new Error(`claim ${owner}: status ${status}`);
+  }
+  console.log(`1. Claimed ${owner}`);
+
+  // 2. The owner connects with the token; an intruder connects without one
+  const ownerClient = await createClient(owner, grant.token);
+  await waitForMessage(ownerClient, 'state');
+  const intruder = await createClient(owner);
+  console.log('2. Owner and intruder connected');
+
+  // 3. After a tick reaches the owner, the intruder has seen only its auth error
+  await waitForMessage(ownerClient, 'tick', TICK_WAIT);
+  const leaked = intruder.messages.filter(m => m.type !== 'error');
+  if (leaked.length) {
+    failures.push(`intruder received ${leaked.map(m => m.type).join(', ')}`);
+  }
+  if (!intruder.messages.some(m => m.type === 'error' && m.code === 'auth_required')) {
+    failures.push('intruder got no auth_required error');
+  }
+  console.log(`3. Intruder received: ${intruder.messages.map(m => m.type).join(', ') || 'nothing'}`);
+
+  // 4. A player that already exists can't be claimed over HTTP...
+  const legacyClient = await createClient(legacy);
+  await waitForMessage(legacyClient, 'state');
+  const taken = await claim(legacy);
+  if (taken.status !== 403) {
+    failures.push(`claiming existing player ${legacy} over HTTP: status ${taken.status}, want 403`);
+  }
+  console.log(`4. HTTP claim of existing ${legacy}: ${taken.status}`);
+
+  // 5. ...but can claim over its own connection
+  legacyClient.ws.send(JSON.stringify({ type: 'claim', requestId: 'c1' }));
+  const ack = await waitForMessage(legacyClient, 'ack');
+  if (ack.requestId !== 'c1' || !ack.session?.token) {
+    failures.push(`claim over the connection: ${JSON.stringify(ack)}`);
+  }
+  console.log(`5. ${legacy} claimed over its connection`);
+
+  ownerClient.ws.close();
+  intruder.ws.close();
+  legacyClient.ws.close();
+
+  if (failures.length) {
+    console.log('\n=== FAILURE ===');
+    failures.forEach(f => console.log(f));
+    process.exit(1);
+  }
+  console.log('\n=== SUCCESS ===');
+  process.exit(0);
+}
+
+test().catch(err => {
+  console.error('Test failed:', err.message);
+  process.exit(1);
+});